@@ -0,0 +1,51 @@
+// Command migrate-state brings state written by older releases up to date:
+// it encrypts state versions that were stored as plaintext, moves state, run
+// logs and plans from the database to the blob store and builds the resource
+// inventory of every workspace. It is safe to run while the API server is
+// running and to run more than once.
+package main
+
+import (
+	"log"
+
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/database"
+	"github.com/terraconsole/api/internal/services"
+)
+
+func main() {
+	cfg := config.Load()
+
+	db := database.Connect(cfg)
+	database.Migrate(db)
+
+	blobs, err := services.NewBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure blob store: %v", err)
+	}
+	states := services.NewStateService(db, services.NewEncryptionService(cfg.EncryptionKey), blobs)
+
+	count, err := states.EncryptPlaintext()
+	if err != nil {
+		log.Fatalf("State migration failed after %d versions: %v", count, err)
+	}
+	log.Printf("Encrypted %d plaintext state versions", count)
+
+	count, err = states.MoveToBlobStore()
+	if err != nil {
+		log.Fatalf("Moving state to the blob store failed after %d versions: %v", count, err)
+	}
+	log.Printf("Moved %d state versions to the blob store", count)
+
+	count, err = services.MoveRunTextToBlobStore(db, blobs)
+	if err != nil {
+		log.Fatalf("Moving run logs to the blob store failed after %d: %v", count, err)
+	}
+	log.Printf("Moved %d run logs and plans to the blob store", count)
+
+	count, err = states.IndexCurrent()
+	if err != nil {
+		log.Fatalf("Resource inventory failed after %d workspaces: %v", count, err)
+	}
+	log.Printf("Indexed resources of %d workspaces", count)
+}
@@ -1,36 +1,59 @@
-package main
-
-import (
-	"fmt"
-	"log"
-	"net/http"
-	"os"
-
-	"github.com/terraconsole/api/internal/config"
-	"github.com/terraconsole/api/internal/database"
-	"github.com/terraconsole/api/internal/handlers"
-)
-
-func main() {
-	cfg := config.Load()
-
-	// Ensure directories exist
-	os.MkdirAll(cfg.TerraformDir, 0755)
-	os.MkdirAll(cfg.WorkingDir, 0755)
-
-	// Connect to database
-	db := database.Connect(cfg)
-	database.Migrate(db)
-
-	// Create router
-	router := handlers.NewRouter(cfg, db)
-
-	addr := fmt.Sprintf(":%s", cfg.Port)
-	log.Printf("TerraConsole API server starting on %s", addr)
-	log.Printf("Terraform versions directory: %s", cfg.TerraformDir)
-	log.Printf("Working directory: %s", cfg.WorkingDir)
-
-	if err := http.ListenAndServe(addr, router); err != nil {
-		log.Fatalf("Server failed: %v", err)
-	}
-}
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/database"
+	"github.com/terraconsole/api/internal/handlers"
+	"github.com/terraconsole/api/internal/services"
+)
+
+func main() {
+	cfg := config.Load()
+
+	// Ensure directories exist
+	os.MkdirAll(cfg.TerraformDir, 0755)
+	os.MkdirAll(cfg.WorkingDir, 0755)
+
+	// Connect to database
+	db := database.Connect(cfg)
+	database.Migrate(db)
+
+	rdb := database.ConnectRedis(cfg)
+
+	encryptor := services.NewEncryptionService(cfg.EncryptionKey)
+
+	blobs, err := services.NewBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure blob store: %v", err)
+	}
+
+	states := services.NewStateService(db, encryptor, blobs)
+	if cfg.StateRetentionIntervalMinutes > 0 {
+		states.StartRetentionJanitor(context.Background(), time.Duration(cfg.StateRetentionIntervalMinutes)*time.Minute)
+	}
+
+	// Start run executor
+	plans := services.NewLocalPlanStore(filepath.Join(cfg.WorkingDir, "plans"))
+	executor := services.NewRunExecutor(db, cfg, encryptor, plans, blobs, states, services.NewRunQueue(rdb))
+	executor.Start(context.Background())
+
+	// Create router
+	router := handlers.NewRouter(cfg, db, encryptor, blobs, states, executor)
+
+	addr := fmt.Sprintf(":%s", cfg.Port)
+	log.Printf("TerraConsole API server starting on %s", addr)
+	log.Printf("Terraform versions directory: %s", cfg.TerraformDir)
+	log.Printf("Working directory: %s", cfg.WorkingDir)
+
+	if err := http.ListenAndServe(addr, router); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
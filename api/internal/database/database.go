@@ -1,53 +1,79 @@
-package database
-
-import (
-	"log"
-
-	"github.com/terraconsole/api/internal/config"
-	"github.com/terraconsole/api/internal/models"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-)
-
-func Connect(cfg *config.Config) *gorm.DB {
-	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-
-	sqlDB, err := db.DB()
-	if err != nil {
-		log.Fatalf("Failed to get database instance: %v", err)
-	}
-	sqlDB.SetMaxOpenConns(25)
-	sqlDB.SetMaxIdleConns(5)
-
-	log.Println("Connected to database successfully")
-	return db
-}
-
-func Migrate(db *gorm.DB) {
-	err := db.AutoMigrate(
-		&models.User{},
-		&models.APIToken{},
-		&models.Organization{},
-		&models.OrgMember{},
-		&models.Project{},
-		&models.Workspace{},
-		&models.Variable{},
-		&models.VariableSet{},
-		&models.VariableSetVariable{},
-		&models.VariableSetWorkspace{},
-		&models.Run{},
-		&models.StateVersion{},
-		&models.AuditLog{},
-		&models.Notification{},
-	)
-	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
-	}
-	log.Println("Database migration completed")
-}
+package database
+
+import (
+	"context"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func Connect(cfg *config.Config) *gorm.DB {
+	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Info),
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database instance: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(25)
+	sqlDB.SetMaxIdleConns(5)
+
+	log.Println("Connected to database successfully")
+	return db
+}
+
+func ConnectRedis(cfg *config.Config) *redis.Client {
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		log.Fatalf("Invalid Redis URL: %v", err)
+	}
+
+	rdb := redis.NewClient(opts)
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	log.Println("Connected to Redis successfully")
+	return rdb
+}
+
+func Migrate(db *gorm.DB) {
+	err := db.AutoMigrate(
+		&models.User{},
+		&models.Session{},
+		&models.MFARecoveryCode{},
+		&models.APIToken{},
+		&models.OAuthCode{},
+		&models.Organization{},
+		&models.OrgMember{},
+		&models.Team{},
+		&models.TeamMember{},
+		&models.TeamAccess{},
+		&models.Project{},
+		&models.Workspace{},
+		&models.RemoteStateConsumer{},
+		&models.Variable{},
+		&models.VariableSet{},
+		&models.VariableSetVariable{},
+		&models.VariableSetWorkspace{},
+		&models.ConfigurationVersion{},
+		&models.Run{},
+		&models.StateVersion{},
+		&models.StateResource{},
+		&models.AuditLog{},
+		&models.Notification{},
+	)
+	if err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	log.Println("Database migration completed")
+}
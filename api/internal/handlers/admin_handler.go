@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+	"gorm.io/gorm"
+)
+
+type AdminHandler struct {
+	db       *gorm.DB
+	executor *services.RunExecutor
+	states   *services.StateService
+}
+
+func NewAdminHandler(db *gorm.DB, executor *services.RunExecutor, states *services.StateService) *AdminHandler {
+	return &AdminHandler{db: db, executor: executor, states: states}
+}
+
+// RunCapacity shows how much of the run concurrency limits is in use and
+// which runs are waiting.
+func (h *AdminHandler) RunCapacity(w http.ResponseWriter, r *http.Request) {
+	report, err := h.executor.Capacity(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read run queue"})
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// EnforceStateRetention runs a state retention pass now instead of waiting
+// for the janitor, and reports what it reclaimed.
+func (h *AdminHandler) EnforceStateRetention(w http.ResponseWriter, r *http.Request) {
+	report, err := h.states.EnforceRetention(time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to enforce state retention"})
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// SetOrgRunLimit overrides the concurrent run limit of an organization.
+// Zero restores the configured default.
+func (h *AdminHandler) SetOrgRunLimit(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+
+	limit, ok := decodeRunLimit(w, r)
+	if !ok {
+		return
+	}
+
+	result := h.db.Model(&models.Organization{}).Where("id = ?", orgID).Update("max_concurrent_runs", limit)
+	if result.RowsAffected == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Organization not found"})
+		return
+	}
+
+	// A raised limit may let waiting runs start.
+	h.executor.Dispatch()
+
+	writeJSON(w, http.StatusOK, map[string]int{"max_concurrent_runs": limit})
+}
+
+// SetProjectRunLimit overrides the concurrent run limit of a project.
+// Zero restores the configured default.
+func (h *AdminHandler) SetProjectRunLimit(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "projectId")
+
+	limit, ok := decodeRunLimit(w, r)
+	if !ok {
+		return
+	}
+
+	result := h.db.Model(&models.Project{}).Where("id = ?", projectID).Update("max_concurrent_runs", limit)
+	if result.RowsAffected == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Project not found"})
+		return
+	}
+
+	h.executor.Dispatch()
+
+	writeJSON(w, http.StatusOK, map[string]int{"max_concurrent_runs": limit})
+}
+
+func decodeRunLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	var req struct {
+		MaxConcurrentRuns *int `json:"max_concurrent_runs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MaxConcurrentRuns == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "max_concurrent_runs is required"})
+		return 0, false
+	}
+	if *req.MaxConcurrentRuns < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "max_concurrent_runs cannot be negative"})
+		return 0, false
+	}
+	return *req.MaxConcurrentRuns, true
+}
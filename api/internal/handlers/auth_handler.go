@@ -1,293 +1,501 @@
-package handlers
-
-import (
-	"encoding/json"
-	"net/http"
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/pquerna/otp/totp"
-	qrcode "github.com/skip2/go-qrcode"
-	"github.com/terraconsole/api/internal/config"
-	"github.com/terraconsole/api/internal/middleware"
-	"github.com/terraconsole/api/internal/models"
-	"github.com/terraconsole/api/internal/services"
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
-	"encoding/base64"
-)
-
-type AuthHandler struct {
-	db        *gorm.DB
-	cfg       *config.Config
-	encryptor *services.EncryptionService
-}
-
-func NewAuthHandler(db *gorm.DB, cfg *config.Config, enc *services.EncryptionService) *AuthHandler {
-	return &AuthHandler{db: db, cfg: cfg, encryptor: enc}
-}
-
-type SignupRequest struct {
-	Email    string `json:"email"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	FullName string `json:"full_name"`
-}
-
-type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	TOTPCode string `json:"totp_code,omitempty"`
-}
-
-type AuthResponse struct {
-	Token     string      `json:"token"`
-	ExpiresAt int64       `json:"expires_at"`
-	User      models.User `json:"user"`
-}
-
-func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
-	var req SignupRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
-		return
-	}
-
-	if req.Email == "" || req.Password == "" || req.Username == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Email, username, and password are required"})
-		return
-	}
-
-	if len(req.Password) < 8 {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Password must be at least 8 characters"})
-		return
-	}
-
-	// Check if user exists
-	var existingUser models.User
-	if err := h.db.Where("email = ? OR username = ?", req.Email, req.Username).First(&existingUser).Error; err == nil {
-		writeJSON(w, http.StatusConflict, map[string]string{"error": "User with this email or username already exists"})
-		return
-	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to hash password"})
-		return
-	}
-
-	user := models.User{
-		Email:    req.Email,
-		Username: req.Username,
-		Password: string(hashedPassword),
-		FullName: req.FullName,
-		IsActive: true,
-	}
-
-	if err := h.db.Create(&user).Error; err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
-		return
-	}
-
-	token, expiresAt, err := h.generateToken(&user)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
-		return
-	}
-
-	writeJSON(w, http.StatusCreated, AuthResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-		User:      user,
-	})
-}
-
-func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
-		return
-	}
-
-	var user models.User
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid email or password"})
-		return
-	}
-
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid email or password"})
-		return
-	}
-
-	// Check MFA
-	if user.MFAEnabled {
-		if req.TOTPCode == "" {
-			writeJSON(w, http.StatusPreconditionRequired, map[string]string{
-				"error":    "MFA code required",
-				"mfa_required": "true",
-			})
-			return
-		}
-
-		secret, err := h.encryptor.Decrypt(user.MFASecret)
-		if err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to verify MFA"})
-			return
-		}
-
-		if !totp.Validate(req.TOTPCode, secret) {
-			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid MFA code"})
-			return
-		}
-	}
-
-	// Update last login
-	now := time.Now()
-	h.db.Model(&user).Update("last_login_at", &now)
-
-	token, expiresAt, err := h.generateToken(&user)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
-		return
-	}
-
-	writeJSON(w, http.StatusOK, AuthResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-		User:      user,
-	})
-}
-
-func (h *AuthHandler) SetupMFA(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user == nil {
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-		return
-	}
-
-	key, err := totp.Generate(totp.GenerateOpts{
-		Issuer:      "TerraConsole",
-		AccountName: user.Email,
-	})
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate MFA secret"})
-		return
-	}
-
-	// Encrypt and store the secret temporarily
-	encSecret, err := h.encryptor.Encrypt(key.Secret())
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to encrypt MFA secret"})
-		return
-	}
-
-	h.db.Model(user).Update("mfa_secret", encSecret)
-
-	// Generate QR code
-	png, err := qrcode.Encode(key.URL(), qrcode.Medium, 256)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate QR code"})
-		return
-	}
-
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"secret":  key.Secret(),
-		"qr_code": "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
-		"url":     key.URL(),
-	})
-}
-
-func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user == nil {
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-		return
-	}
-
-	var req struct {
-		Code string `json:"code"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
-		return
-	}
-
-	secret, err := h.encryptor.Decrypt(user.MFASecret)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "MFA not set up"})
-		return
-	}
-
-	if !totp.Validate(req.Code, secret) {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid MFA code"})
-		return
-	}
-
-	h.db.Model(user).Update("mfa_enabled", true)
-
-	writeJSON(w, http.StatusOK, map[string]string{"message": "MFA enabled successfully"})
-}
-
-func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user == nil {
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-		return
-	}
-
-	h.db.Model(user).Updates(map[string]interface{}{
-		"mfa_enabled": false,
-		"mfa_secret":  "",
-	})
-
-	writeJSON(w, http.StatusOK, map[string]string{"message": "MFA disabled successfully"})
-}
-
-func (h *AuthHandler) GetMe(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user == nil {
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-		return
-	}
-	writeJSON(w, http.StatusOK, user)
-}
-
-func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user == nil {
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-		return
-	}
-
-	token, expiresAt, err := h.generateToken(user)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
-		return
-	}
-
-	writeJSON(w, http.StatusOK, AuthResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-		User:      *user,
-	})
-}
-
-func (h *AuthHandler) generateToken(user *models.User) (string, int64, error) {
-	expiresAt := time.Now().Add(24 * time.Hour)
-
-	claims := jwt.MapClaims{
-		"sub":   user.ID,
-		"email": user.Email,
-		"exp":   expiresAt.Unix(),
-		"iat":   time.Now().Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(h.cfg.JWTSecret))
-	if err != nil {
-		return "", 0, err
-	}
-
-	return tokenString, expiresAt.Unix(), nil
-}
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	qrcode "github.com/skip2/go-qrcode"
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type AuthHandler struct {
+	db        *gorm.DB
+	cfg       *config.Config
+	encryptor *services.EncryptionService
+}
+
+func NewAuthHandler(db *gorm.DB, cfg *config.Config, enc *services.EncryptionService) *AuthHandler {
+	return &AuthHandler{db: db, cfg: cfg, encryptor: enc}
+}
+
+type SignupRequest struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	FullName string `json:"full_name"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	TOTPCode string `json:"totp_code,omitempty"`
+}
+
+// AuthResponse is a signed-in session: a short-lived access token and the
+// refresh token to get the next one with.
+type AuthResponse struct {
+	Token        string      `json:"token"`
+	ExpiresAt    int64       `json:"expires_at"`
+	RefreshToken string      `json:"refresh_token"`
+	User         models.User `json:"user"`
+}
+
+func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
+	var req SignupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if req.Email == "" || req.Password == "" || req.Username == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Email, username, and password are required"})
+		return
+	}
+
+	if len(req.Password) < 8 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Password must be at least 8 characters"})
+		return
+	}
+
+	// Check if user exists
+	var existingUser models.User
+	if err := h.db.Where("email = ? OR username = ?", req.Email, req.Username).First(&existingUser).Error; err == nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "User with this email or username already exists"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to hash password"})
+		return
+	}
+
+	user := models.User{
+		Email:    req.Email,
+		Username: req.Username,
+		Password: string(hashedPassword),
+		FullName: req.FullName,
+		IsActive: true,
+	}
+
+	if err := h.db.Create(&user).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
+		return
+	}
+
+	resp, err := h.startSession(r, &user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid email or password"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid email or password"})
+		return
+	}
+
+	// Check MFA
+	if user.MFAEnabled {
+		if req.TOTPCode == "" {
+			writeJSON(w, http.StatusPreconditionRequired, map[string]string{
+				"error":        "MFA code required",
+				"mfa_required": "true",
+			})
+			return
+		}
+
+		ok, err := h.checkMFACode(&user, req.TOTPCode)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to verify MFA"})
+			return
+		}
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid MFA code"})
+			return
+		}
+	}
+
+	// Update last login
+	now := time.Now()
+	h.db.Model(&user).Update("last_login_at", &now)
+
+	resp, err := h.startSession(r, &user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// SetupMFA starts enrolling an authenticator. The new secret only replaces
+// the current one once VerifyMFA confirms it, and re-enrolling while MFA is
+// enabled takes a current authentication or recovery code.
+func (h *AuthHandler) SetupMFA(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	if !canManageMFA(w, r) {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if user.MFAEnabled && !h.requireMFACode(w, user, req.Code) {
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      "TerraConsole",
+		AccountName: user.Email,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate MFA secret"})
+		return
+	}
+
+	// Encrypt and keep the secret pending until it is verified
+	encSecret, err := h.encryptor.Encrypt(key.Secret())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to encrypt MFA secret"})
+		return
+	}
+
+	if err := h.db.Model(user).Update("mfa_pending_secret", encSecret).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to save MFA secret"})
+		return
+	}
+
+	// Generate QR code
+	png, err := qrcode.Encode(key.URL(), qrcode.Medium, 256)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate QR code"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"secret":  key.Secret(),
+		"qr_code": "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		"url":     key.URL(),
+	})
+}
+
+// VerifyMFA confirms a pending enrollment with a code from the new
+// authenticator, enables MFA with it and returns a fresh set of recovery
+// codes. The codes are only ever shown here and by RegenerateRecoveryCodes.
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	if !canManageMFA(w, r) {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if user.MFAPendingSecret == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "MFA not set up"})
+		return
+	}
+	secret, err := h.encryptor.Decrypt(user.MFAPendingSecret)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to verify MFA"})
+		return
+	}
+
+	if !totp.Validate(req.Code, secret) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid MFA code"})
+		return
+	}
+
+	var codes []string
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"mfa_enabled":        true,
+			"mfa_secret":         user.MFAPendingSecret,
+			"mfa_pending_secret": "",
+		}).Error
+		if err != nil {
+			return err
+		}
+		codes, err = services.NewRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to enable MFA"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":        "MFA enabled successfully",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableMFA turns MFA off given a current authentication or recovery code.
+// Members of an organization that requires MFA cannot turn it off.
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	if !canManageMFA(w, r) {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if !user.MFAEnabled {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "MFA is not enabled"})
+		return
+	}
+
+	var requiring int64
+	err := h.db.Model(&models.Organization{}).
+		Joins("JOIN org_members ON org_members.organization_id = organizations.id").
+		Where("org_members.user_id = ? AND organizations.require_mfa", user.ID).
+		Count(&requiring).Error
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to disable MFA"})
+		return
+	}
+	if requiring > 0 {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "An organization you belong to requires MFA"})
+		return
+	}
+
+	if !h.requireMFACode(w, user, req.Code) {
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"mfa_enabled":        false,
+			"mfa_secret":         "",
+			"mfa_pending_secret": "",
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.MFARecoveryCode{}).Error
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to disable MFA"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "MFA disabled successfully"})
+}
+
+// GetRecoveryCodes reports how many of the caller's recovery codes are unused.
+func (h *AuthHandler) GetRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	remaining, err := services.RemainingRecoveryCodes(h.db, user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to count recovery codes"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"remaining": remaining})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes, given a
+// current authentication or recovery code, and returns the new ones.
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	if !canManageMFA(w, r) {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if !user.MFAEnabled {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "MFA is not enabled"})
+		return
+	}
+	if !h.requireMFACode(w, user, req.Code) {
+		return
+	}
+
+	codes, err := services.NewRecoveryCodes(h.db, user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate recovery codes"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// checkMFACode reports whether code is a valid authentication code for the
+// user's enrolled authenticator or one of their unused recovery codes, which
+// it spends.
+func (h *AuthHandler) checkMFACode(user *models.User, code string) (bool, error) {
+	if code == "" {
+		return false, nil
+	}
+	secret, err := h.encryptor.Decrypt(user.MFASecret)
+	if err != nil {
+		return false, err
+	}
+	if totp.Validate(code, secret) {
+		return true, nil
+	}
+	return services.UseRecoveryCode(h.db, user.ID, code, time.Now())
+}
+
+// requireMFACode answers with 403 unless code passes checkMFACode.
+func (h *AuthHandler) requireMFACode(w http.ResponseWriter, user *models.User, code string) bool {
+	ok, err := h.checkMFACode(user, code)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to verify MFA"})
+		return false
+	}
+	if !ok {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "A valid authentication or recovery code is required"})
+		return false
+	}
+	return true
+}
+
+// canManageMFA keeps organization and team tokens, which act for the admin
+// who created them, from changing that admin's MFA.
+func canManageMFA(w http.ResponseWriter, r *http.Request) bool {
+	if token := middleware.GetAPIToken(r); token != nil && token.Kind != models.APITokenUser {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Organization and team tokens cannot manage MFA"})
+		return false
+	}
+	return true
+}
+
+func (h *AuthHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. The old refresh token stops working.
+func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "refresh_token is required"})
+		return
+	}
+
+	session, refreshToken, err := services.RotateSession(h.db, req.RefreshToken, sessionClient(r), h.refreshTTL(), time.Now())
+	if errors.Is(err, services.ErrInvalidRefreshToken) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to refresh session"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", session.UserID).Error; err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "User not found"})
+		return
+	}
+	if !user.IsActive {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Account is disabled"})
+		return
+	}
+
+	resp, err := h.authResponse(&user, session.ID, refreshToken)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// startSession signs a user in on the device the request came from.
+func (h *AuthHandler) startSession(r *http.Request, user *models.User) (*AuthResponse, error) {
+	session, refreshToken, err := services.NewSession(h.db, user.ID, sessionClient(r), h.refreshTTL())
+	if err != nil {
+		return nil, err
+	}
+	return h.authResponse(user, session.ID, refreshToken)
+}
+
+func (h *AuthHandler) authResponse(user *models.User, sessionID, refreshToken string) (*AuthResponse, error) {
+	token, expiresAt, err := services.NewAccessToken(h.cfg.JWTSecret, user, sessionID, time.Duration(h.cfg.AccessTokenTTLMinutes)*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{
+		Token:        token,
+		ExpiresAt:    expiresAt.Unix(),
+		RefreshToken: refreshToken,
+		User:         *user,
+	}, nil
+}
+
+func (h *AuthHandler) refreshTTL() time.Duration {
+	return time.Duration(h.cfg.RefreshTokenTTLDays) * 24 * time.Hour
+}
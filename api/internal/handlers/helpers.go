@@ -1,12 +1,79 @@
-package handlers
-
-import (
-	"encoding/json"
-	"net/http"
-)
-
-func writeJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
-}
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// allowedOrigin checks WebSocket handshakes against the configured CORS
+// origins. Requests without an Origin header (non-browser clients) pass.
+func allowedOrigin(cfg *config.Config) func(r *http.Request) bool {
+	origins := strings.Split(cfg.AllowedOrigins, ",")
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, o := range origins {
+			if strings.TrimSpace(o) == origin {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// recordAudit writes an audit log entry for the request's user. Details are
+// stored as JSON.
+func recordAudit(db *gorm.DB, r *http.Request, entry models.AuditLog, details interface{}) {
+	if user := middleware.GetUser(r); user != nil {
+		entry.UserID = user.ID
+	}
+	entry.IPAddress = r.RemoteAddr
+	if details != nil {
+		data, _ := json.Marshal(details)
+		entry.Details = string(data)
+	}
+
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to write audit log %s: %v", entry.Action, err)
+	}
+}
+
+// findMember looks up the caller's membership of an organization. A request
+// made with an organization or team token only reaches the token's
+// organization.
+func findMember(db *gorm.DB, r *http.Request, orgID string) (*models.OrgMember, error) {
+	return middleware.FindMember(db, r, orgID)
+}
+
+// hasOrgRole reports whether the caller has one of the roles in an
+// organization. Team tokens have no role, only their team's grants.
+func hasOrgRole(db *gorm.DB, r *http.Request, orgID string, roles ...models.OrgRole) bool {
+	if token := middleware.GetAPIToken(r); token != nil && token.TeamID != nil {
+		return false
+	}
+	member, err := findMember(db, r, orgID)
+	if err != nil {
+		return false
+	}
+	for _, role := range roles {
+		if member.Role == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+// stateAccess is how much of a workspace's state a caller may read.
+type stateAccess int
+
+const (
+	stateAccessNone stateAccess = iota
+	// stateAccessOutputs allows reading outputs only, as the runs of
+	// workspaces the state is shared with do.
+	stateAccessOutputs
+	stateAccessFull
+	// stateAccessRaw also allows downloading the state document itself,
+	// which holds sensitive values in clear.
+	stateAccessRaw
+)
+
+// stateAccess decides what the caller may read of a workspace's state.
+// Members of the workspace's organization whose role grants read-state, and
+// the workspace's own runs, read all of it; the workspace's runs and members
+// who may also reveal outputs download the raw document.
+// Runs of other workspaces read its outputs if the workspace shares its
+// remote state with them.
+func (h *StateHandler) stateAccess(r *http.Request, wsID string) (stateAccess, error) {
+	var workspace models.Workspace
+	if err := h.db.Preload("Project").Select("id", "project_id", "remote_state_sharing").First(&workspace, "id = ?", wsID).Error; err != nil {
+		return stateAccessNone, err
+	}
+
+	consumerID := middleware.GetRunWorkspace(r)
+	if consumerID == "" {
+		access, err := middleware.ResolveAccess(h.db, r, middleware.Scope{
+			OrganizationID: workspace.Project.OrganizationID,
+			ProjectID:      workspace.ProjectID,
+			WorkspaceID:    workspace.ID,
+			ResourceType:   "workspace",
+			ResourceID:     workspace.ID,
+		})
+		if err != nil {
+			return stateAccessNone, err
+		}
+		if !access.Can(models.PermissionReadState) {
+			middleware.AuditDenied(h.db, r, access, models.PermissionReadState)
+			return stateAccessNone, nil
+		}
+		if access.Can(models.PermissionRevealOutputs) {
+			return stateAccessRaw, nil
+		}
+		return stateAccessFull, nil
+	}
+	if consumerID == wsID {
+		return stateAccessRaw, nil
+	}
+
+	switch workspace.RemoteStateSharing {
+	case models.RemoteStateSharingOrganization:
+		var consumer models.Workspace
+		err := h.db.Preload("Project").Select("id", "project_id").First(&consumer, "id = ?", consumerID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return stateAccessNone, nil
+		}
+		if err != nil {
+			return stateAccessNone, err
+		}
+		if consumer.Project.OrganizationID == workspace.Project.OrganizationID {
+			return stateAccessOutputs, nil
+		}
+	case models.RemoteStateSharingWorkspaces:
+		var count int64
+		if err := h.db.Model(&models.RemoteStateConsumer{}).Where("workspace_id = ? AND consumer_id = ?", wsID, consumerID).Count(&count).Error; err != nil {
+			return stateAccessNone, err
+		}
+		if count > 0 {
+			return stateAccessOutputs, nil
+		}
+	}
+	return stateAccessNone, nil
+}
+
+// requireStateAccess checks that the caller has at least the given access to
+// a workspace's state and writes the error response if not.
+func (h *StateHandler) requireStateAccess(w http.ResponseWriter, r *http.Request, wsID string, required stateAccess) (stateAccess, bool) {
+	access, err := h.stateAccess(r, wsID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return access, false
+	}
+	if errors.Is(err, middleware.ErrMFARequired) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "This organization requires MFA. Enable it under Settings to continue."})
+		return access, false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to check state access"})
+		return access, false
+	}
+	if access < required {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "This workspace does not share its state with you"})
+		return access, false
+	}
+	return access, true
+}
+
+// outputsOnlyState strips a state document down to its outputs, which is all
+// terraform_remote_state reads.
+func outputsOnlyState(data []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for key := range doc {
+		switch key {
+		case "version", "terraform_version", "serial", "lineage", "outputs":
+		default:
+			delete(doc, key)
+		}
+	}
+	doc["resources"] = json.RawMessage("[]")
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// GetRemoteStateSharing shows which workspaces may read this workspace's
+// outputs.
+func (h *StateHandler) GetRemoteStateSharing(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	if _, ok := h.requireStateAccess(w, r, wsID, stateAccessFull); !ok {
+		return
+	}
+
+	var workspace models.Workspace
+	if err := h.db.Select("id", "remote_state_sharing").First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return
+	}
+	h.writeRemoteStateSharing(w, &workspace)
+}
+
+// UpdateRemoteStateSharing sets who may read this workspace's outputs: the
+// whole organization, the listed workspaces of the organization, or nobody.
+func (h *StateHandler) UpdateRemoteStateSharing(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	var req struct {
+		Sharing      models.RemoteStateSharing `json:"sharing"`
+		WorkspaceIDs []string                  `json:"workspace_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+	switch req.Sharing {
+	case models.RemoteStateSharingNone, models.RemoteStateSharingOrganization, models.RemoteStateSharingWorkspaces:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "sharing must be none, organization or workspaces"})
+		return
+	}
+	if req.Sharing != models.RemoteStateSharingWorkspaces {
+		req.WorkspaceIDs = nil
+	}
+	req.WorkspaceIDs = uniqueStrings(req.WorkspaceIDs)
+
+	if _, ok := h.requireStateAccess(w, r, wsID, stateAccessFull); !ok {
+		return
+	}
+
+	var workspace models.Workspace
+	if err := h.db.Preload("Project").First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return
+	}
+
+	if len(req.WorkspaceIDs) > 0 {
+		var count int64
+		h.db.Model(&models.Workspace{}).
+			Joins("JOIN projects ON projects.id = workspaces.project_id").
+			Where("workspaces.id IN ? AND projects.organization_id = ?", req.WorkspaceIDs, workspace.Project.OrganizationID).
+			Count(&count)
+		if int(count) != len(req.WorkspaceIDs) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "State can only be shared with workspaces of the same organization"})
+			return
+		}
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Workspace{}).Where("id = ?", wsID).Update("remote_state_sharing", req.Sharing).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id = ?", wsID).Delete(&models.RemoteStateConsumer{}).Error; err != nil {
+			return err
+		}
+		for _, consumerID := range req.WorkspaceIDs {
+			if consumerID == wsID {
+				continue
+			}
+			if err := tx.Create(&models.RemoteStateConsumer{WorkspaceID: wsID, ConsumerID: consumerID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update remote state sharing"})
+		return
+	}
+
+	workspace.RemoteStateSharing = req.Sharing
+	h.writeRemoteStateSharing(w, &workspace)
+}
+
+func (h *StateHandler) writeRemoteStateSharing(w http.ResponseWriter, workspace *models.Workspace) {
+	consumers := []string{}
+	h.db.Model(&models.RemoteStateConsumer{}).Where("workspace_id = ?", workspace.ID).Order("consumer_id").Pluck("consumer_id", &consumers)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sharing":       workspace.RemoteStateSharing,
+		"workspace_ids": consumers,
+	})
+}
+
+// writeOutputsOnlyState sends a state document reduced to its outputs.
+func (h *StateHandler) writeOutputsOnlyState(w http.ResponseWriter, state *models.StateVersion) {
+	data, err := h.states.Data(state)
+	if err == nil {
+		data, err = outputsOnlyState(data)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read state"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+// ResourceHandler serves the resource inventory built from workspace state.
+type ResourceHandler struct {
+	db *gorm.DB
+}
+
+func NewResourceHandler(db *gorm.DB) *ResourceHandler {
+	return &ResourceHandler{db: db}
+}
+
+// List returns the resources in a workspace's current state, filtered by
+// type, name, module, provider and mode.
+func (h *ResourceHandler) List(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	var resources []models.StateResource
+	query := filterResources(h.db.Where("workspace_id = ?", wsID), r.URL.Query())
+	if err := query.Order("address ASC").Find(&resources).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list resources"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resources)
+}
+
+// Search finds resources across all workspaces of an organization, e.g.
+// which workspaces manage an aws_s3_bucket with ID my-bucket. It takes the
+// same filters as List plus id, matched against instance IDs.
+func (h *ResourceHandler) Search(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+
+	params := r.URL.Query()
+	if params.Get("type") == "" && params.Get("name") == "" && params.Get("id") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Search by at least one of type, name or id"})
+		return
+	}
+
+	type result struct {
+		models.StateResource
+		WorkspaceName string `json:"workspace_name"`
+		ProjectID     string `json:"project_id"`
+		ProjectName   string `json:"project_name"`
+	}
+
+	query := h.db.Table("state_resources").
+		Select("state_resources.*, workspaces.name AS workspace_name, projects.id AS project_id, projects.name AS project_name").
+		Joins("JOIN workspaces ON workspaces.id = state_resources.workspace_id AND workspaces.deleted_at IS NULL").
+		Joins("JOIN projects ON projects.id = workspaces.project_id AND projects.deleted_at IS NULL").
+		Where("projects.organization_id = ?", orgID)
+	if id := params.Get("id"); id != "" {
+		query = query.Where("? = ANY(state_resources.instance_ids)", id)
+	}
+
+	var results []result
+	if err := filterResources(query, params).Order("workspaces.name ASC, state_resources.address ASC").Limit(500).Find(&results).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to search resources"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// filterResources applies the inventory filters in params. A provider
+// matches by full source address or by name, so aws matches
+// registry.terraform.io/hashicorp/aws; module "root" selects the root module.
+func filterResources(query *gorm.DB, params url.Values) *gorm.DB {
+	if v := params.Get("type"); v != "" {
+		query = query.Where("state_resources.type = ?", v)
+	}
+	if v := params.Get("name"); v != "" {
+		query = query.Where("state_resources.name = ?", v)
+	}
+	if v := params.Get("mode"); v != "" {
+		query = query.Where("state_resources.mode = ?", v)
+	}
+	if v := params.Get("module"); v != "" {
+		if v == "root" {
+			v = ""
+		}
+		query = query.Where("state_resources.module = ?", v)
+	}
+	if v := params.Get("provider"); v != "" {
+		query = query.Where("(state_resources.provider = ? OR state_resources.provider LIKE ?)", v, "%/"+v)
+	}
+	return query
+}
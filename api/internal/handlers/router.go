@@ -1,152 +1,278 @@
-package handlers
-
-import (
-	"net/http"
-
-	"github.com/go-chi/chi/v5"
-	chimw "github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-	"github.com/terraconsole/api/internal/config"
-	"github.com/terraconsole/api/internal/middleware"
-	"github.com/terraconsole/api/internal/services"
-	"gorm.io/gorm"
-	"strings"
-)
-
-func NewRouter(cfg *config.Config, db *gorm.DB) http.Handler {
-	r := chi.NewRouter()
-
-	// Middleware
-	r.Use(chimw.Logger)
-	r.Use(chimw.Recoverer)
-	r.Use(chimw.RequestID)
-	r.Use(chimw.RealIP)
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   strings.Split(cfg.AllowedOrigins, ","),
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Requested-With"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
-
-	encryptor := services.NewEncryptionService(cfg.EncryptionKey)
-
-	// Handlers
-	authHandler := NewAuthHandler(db, cfg, encryptor)
-	orgHandler := NewOrgHandler(db)
-	projectHandler := NewProjectHandler(db)
-	workspaceHandler := NewWorkspaceHandler(db, encryptor)
-	runHandler := NewRunHandler(db)
-	stateHandler := NewStateHandler(db, encryptor)
-	tfVersionHandler := NewTFVersionHandler(cfg)
-
-	// Health check
-	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, http.StatusOK, map[string]string{
-			"status":  "ok",
-			"version": "1.0.0",
-			"service": "terraconsole",
-		})
-	})
-
-	// Auth routes (public)
-	r.Route("/api/auth", func(r chi.Router) {
-		r.Post("/signup", authHandler.Signup)
-		r.Post("/login", authHandler.Login)
-	})
-
-	// Protected routes
-	r.Group(func(r chi.Router) {
-		r.Use(middleware.AuthMiddleware(cfg, db))
-
-		// Auth
-		r.Get("/api/auth/me", authHandler.GetMe)
-		r.Post("/api/auth/refresh", authHandler.RefreshToken)
-		r.Post("/api/auth/mfa/setup", authHandler.SetupMFA)
-		r.Post("/api/auth/mfa/verify", authHandler.VerifyMFA)
-		r.Post("/api/auth/mfa/disable", authHandler.DisableMFA)
-
-		// Terraform versions
-		r.Get("/api/terraform/versions", tfVersionHandler.ListVersions)
-		r.Get("/api/terraform/versions/installed", tfVersionHandler.ListInstalledVersions)
-		r.Post("/api/terraform/versions/{version}/install", tfVersionHandler.InstallVersion)
-
-		// Organizations
-		r.Route("/api/organizations", func(r chi.Router) {
-			r.Get("/", orgHandler.List)
-			r.Post("/", orgHandler.Create)
-			r.Route("/{orgId}", func(r chi.Router) {
-				r.Get("/", orgHandler.Get)
-				r.Put("/", orgHandler.Update)
-				r.Delete("/", orgHandler.Delete)
-
-				// Members
-				r.Get("/members", orgHandler.ListMembers)
-				r.Post("/members", orgHandler.AddMember)
-				r.Put("/members/{memberId}", orgHandler.UpdateMember)
-				r.Delete("/members/{memberId}", orgHandler.RemoveMember)
-
-				// Projects
-				r.Get("/projects", projectHandler.List)
-				r.Post("/projects", projectHandler.Create)
-			})
-		})
-
-		// Projects
-		r.Route("/api/projects/{projectId}", func(r chi.Router) {
-			r.Get("/", projectHandler.Get)
-			r.Put("/", projectHandler.Update)
-			r.Delete("/", projectHandler.Delete)
-
-			// Workspaces
-			r.Get("/workspaces", workspaceHandler.List)
-			r.Post("/workspaces", workspaceHandler.Create)
-		})
-
-		// Workspaces
-		r.Route("/api/workspaces/{workspaceId}", func(r chi.Router) {
-			r.Get("/", workspaceHandler.Get)
-			r.Put("/", workspaceHandler.Update)
-			r.Delete("/", workspaceHandler.Delete)
-			r.Post("/lock", workspaceHandler.Lock)
-			r.Post("/unlock", workspaceHandler.Unlock)
-
-			// Variables
-			r.Get("/variables", workspaceHandler.ListVariables)
-			r.Post("/variables", workspaceHandler.CreateVariable)
-			r.Put("/variables/{variableId}", workspaceHandler.UpdateVariable)
-			r.Delete("/variables/{variableId}", workspaceHandler.DeleteVariable)
-
-			// Runs
-			r.Get("/runs", runHandler.List)
-			r.Post("/runs", runHandler.Create)
-
-			// State
-			r.Get("/state", stateHandler.GetCurrentState)
-			r.Get("/state-versions", stateHandler.ListStateVersions)
-			r.Get("/state-versions/{versionId}", stateHandler.GetStateVersion)
-			r.Get("/outputs", stateHandler.GetOutputs)
-		})
-
-		// Runs
-		r.Route("/api/runs/{runId}", func(r chi.Router) {
-			r.Get("/", runHandler.Get)
-			r.Get("/plan-log", runHandler.GetPlanLog)
-			r.Get("/apply-log", runHandler.GetApplyLog)
-			r.Post("/approve", runHandler.Approve)
-			r.Post("/discard", runHandler.Discard)
-			r.Post("/cancel", runHandler.Cancel)
-		})
-
-		// State HTTP Backend (for terraform remote state)
-		r.Route("/api/state/{workspaceId}", func(r chi.Router) {
-			r.Get("/", stateHandler.HTTPBackendGet)
-			r.Post("/", stateHandler.HTTPBackendPost)
-			r.HandleFunc("/lock", stateHandler.HTTPBackendLock)
-			r.HandleFunc("/unlock", stateHandler.HTTPBackendUnlock)
-		})
-	})
-
-	return r
-}
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+	"gorm.io/gorm"
+	"strings"
+)
+
+func NewRouter(cfg *config.Config, db *gorm.DB, encryptor *services.EncryptionService, blobs services.BlobStore, states *services.StateService, executor *services.RunExecutor) http.Handler {
+	r := chi.NewRouter()
+
+	// Middleware
+	r.Use(chimw.Logger)
+	r.Use(chimw.Recoverer)
+	r.Use(chimw.RequestID)
+	r.Use(chimw.RealIP)
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   strings.Split(cfg.AllowedOrigins, ","),
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Requested-With"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}))
+
+	// Handlers
+	authHandler := NewAuthHandler(db, cfg, encryptor)
+	orgHandler := NewOrgHandler(db)
+	teamHandler := NewTeamHandler(db)
+	tokenHandler := NewTokenHandler(db)
+	projectHandler := NewProjectHandler(db)
+	workspaceHandler := NewWorkspaceHandler(db, encryptor)
+	runHandler := NewRunHandler(db, cfg, blobs, executor)
+	stateHandler := NewStateHandler(db, encryptor, states)
+	resourceHandler := NewResourceHandler(db)
+	tfVersionHandler := NewTFVersionHandler(cfg)
+	adminHandler := NewAdminHandler(db, executor, states)
+	loginHandler := NewTerraformLoginHandler(db)
+	tfeHandler := NewTFEHandler(db, cfg, blobs, states, executor, runHandler)
+
+	// Health check
+	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"status":  "ok",
+			"version": "1.0.0",
+			"service": "terraconsole",
+		})
+	})
+
+	// Auth routes (public)
+	r.Route("/api/auth", func(r chi.Router) {
+		r.Post("/signup", authHandler.Signup)
+		r.Post("/login", authHandler.Login)
+		r.Post("/refresh", authHandler.RefreshToken)
+	})
+
+	// terraform login: service discovery and the token endpoint of the
+	// OAuth flow
+	r.Get("/.well-known/terraform.json", loginHandler.ServiceDiscovery)
+	r.Post("/api/oauth/token", loginHandler.Token)
+
+	// TFE API endpoints terraform calls without credentials. Uploads and
+	// downloads are authorized by the signature in their URL.
+	r.Get("/api/v2/ping", tfeHandler.Ping)
+	r.Put("/api/v2/configuration-versions/{cvId}/upload/{signature}", tfeHandler.UploadConfiguration)
+	r.Get("/api/v2/plans/{runId}/logs/{signature}", tfeHandler.GetPlanLogs)
+	r.Get("/api/v2/applies/{runId}/logs/{signature}", tfeHandler.GetApplyLogs)
+	r.Get("/api/v2/state-versions/{versionId}/download/{signature}", tfeHandler.DownloadStateVersion)
+
+	// Organization role checks for routes addressing a run, workspace,
+	// project or organization
+	read := middleware.Authorize(db, models.PermissionRead)
+	readState := middleware.Authorize(db, models.PermissionReadState)
+	// Raw state documents hold sensitive outputs and attributes in clear.
+	readRawState := middleware.Authorize(db, models.PermissionRevealOutputs)
+	queueRun := middleware.Authorize(db, models.PermissionQueueRun)
+	lock := middleware.Authorize(db, models.PermissionLock)
+	apply := middleware.Authorize(db, models.PermissionApply)
+	writeVariables := middleware.Authorize(db, models.PermissionWriteVariables)
+	manageWorkspaces := middleware.Authorize(db, models.PermissionManageWorkspaces)
+
+	// Protected routes
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware(cfg, db))
+
+		// Auth
+		r.Get("/api/auth/me", authHandler.GetMe)
+		r.Post("/api/auth/logout", authHandler.Logout)
+		r.Post("/api/auth/mfa/setup", authHandler.SetupMFA)
+		r.Post("/api/auth/mfa/verify", authHandler.VerifyMFA)
+		r.Post("/api/auth/mfa/disable", authHandler.DisableMFA)
+		r.Get("/api/auth/mfa/recovery-codes", authHandler.GetRecoveryCodes)
+		r.Post("/api/auth/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+
+		// Sessions
+		r.Get("/api/auth/sessions", authHandler.ListSessions)
+		r.Delete("/api/auth/sessions", authHandler.RevokeAllSessions)
+		r.Delete("/api/auth/sessions/{sessionId}", authHandler.RevokeSession)
+
+		r.Post("/api/oauth/authorize", loginHandler.Authorize)
+
+		// Personal API tokens
+		r.Get("/api/auth/tokens", tokenHandler.ListUserTokens)
+		r.Post("/api/auth/tokens", tokenHandler.CreateUserToken)
+		r.Delete("/api/auth/tokens/{tokenId}", tokenHandler.DeleteUserToken)
+
+		// Terraform versions
+		r.Get("/api/terraform/versions", tfVersionHandler.ListVersions)
+		r.Get("/api/terraform/versions/installed", tfVersionHandler.ListInstalledVersions)
+		r.Post("/api/terraform/versions/{version}/install", tfVersionHandler.InstallVersion)
+
+		// Organizations
+		r.Route("/api/organizations", func(r chi.Router) {
+			r.Get("/", orgHandler.List)
+			r.Post("/", orgHandler.Create)
+			r.Route("/{orgId}", func(r chi.Router) {
+				r.Get("/", orgHandler.Get)
+				r.Put("/", orgHandler.Update)
+				r.Delete("/", orgHandler.Delete)
+
+				// Members
+				r.With(read).Get("/members", orgHandler.ListMembers)
+				r.Post("/members", orgHandler.AddMember)
+				r.Put("/members/{memberId}", orgHandler.UpdateMember)
+				r.Delete("/members/{memberId}", orgHandler.RemoveMember)
+
+				// Teams
+				r.Get("/teams", teamHandler.List)
+				r.Post("/teams", teamHandler.Create)
+				r.Get("/teams/{teamId}", teamHandler.Get)
+				r.Put("/teams/{teamId}", teamHandler.Update)
+				r.Delete("/teams/{teamId}", teamHandler.Delete)
+				r.Post("/teams/{teamId}/members", teamHandler.AddMember)
+				r.Delete("/teams/{teamId}/members/{userId}", teamHandler.RemoveMember)
+				r.Post("/teams/{teamId}/access", teamHandler.GrantAccess)
+				r.Delete("/teams/{teamId}/access/{accessId}", teamHandler.RevokeAccess)
+
+				// Organization and team API tokens
+				r.Get("/tokens", tokenHandler.ListOrgTokens)
+				r.Post("/tokens", tokenHandler.CreateOrgToken)
+				r.Delete("/tokens/{tokenId}", tokenHandler.DeleteOrgToken)
+
+				// Projects
+				r.With(read).Get("/projects", projectHandler.List)
+				r.With(manageWorkspaces).Post("/projects", projectHandler.Create)
+
+				// Resource inventory
+				r.With(readState).Get("/resources", resourceHandler.Search)
+			})
+		})
+
+		// Projects
+		r.Route("/api/projects/{projectId}", func(r chi.Router) {
+			r.With(read).Get("/", projectHandler.Get)
+			r.With(manageWorkspaces).Put("/", projectHandler.Update)
+			r.With(manageWorkspaces).Delete("/", projectHandler.Delete)
+
+			// Workspaces
+			r.With(read).Get("/workspaces", workspaceHandler.List)
+			r.With(manageWorkspaces).Post("/workspaces", workspaceHandler.Create)
+		})
+
+		// Workspaces
+		r.Route("/api/workspaces/{workspaceId}", func(r chi.Router) {
+			r.With(read).Get("/", workspaceHandler.Get)
+			r.With(manageWorkspaces).Put("/", workspaceHandler.Update)
+			r.With(manageWorkspaces).Delete("/", workspaceHandler.Delete)
+			r.With(lock).Post("/lock", workspaceHandler.Lock)
+			r.With(lock).Post("/unlock", workspaceHandler.Unlock)
+			r.With(read).Get("/state-retention", workspaceHandler.GetStateRetention)
+			r.With(manageWorkspaces).Put("/state-retention", workspaceHandler.UpdateStateRetention)
+
+			// Variables
+			r.With(read).Get("/variables", workspaceHandler.ListVariables)
+			r.With(writeVariables).Post("/variables", workspaceHandler.CreateVariable)
+			r.With(writeVariables).Put("/variables/{variableId}", workspaceHandler.UpdateVariable)
+			r.With(writeVariables).Delete("/variables/{variableId}", workspaceHandler.DeleteVariable)
+
+			// Runs
+			r.With(read).Get("/runs", runHandler.List)
+			r.With(queueRun).Post("/runs", runHandler.Create)
+
+			// State
+			r.With(readState, readRawState).Get("/state", stateHandler.GetCurrentState)
+			r.With(readState).Get("/state-versions", stateHandler.ListStateVersions)
+			r.With(apply).Post("/state-versions", stateHandler.UploadStateVersion)
+			r.With(readState, readRawState).Get("/state-versions/{versionId}", stateHandler.GetStateVersion)
+			r.With(readState).Get("/state-versions/{fromVersion}/diff/{toVersion}", stateHandler.DiffStateVersions)
+			r.With(manageWorkspaces).Post("/state-versions/{versionId}/rollback", stateHandler.Rollback)
+			r.With(readState).Get("/outputs", stateHandler.GetOutputs)
+			r.With(readState).Get("/outputs/{name}/reveal", stateHandler.RevealOutput)
+			r.With(read).Get("/remote-state-sharing", stateHandler.GetRemoteStateSharing)
+			r.With(manageWorkspaces).Put("/remote-state-sharing", stateHandler.UpdateRemoteStateSharing)
+			r.With(readState).Get("/resources", resourceHandler.List)
+		})
+
+		// Runs
+		r.Route("/api/runs/{runId}", func(r chi.Router) {
+			r.With(read).Get("/", runHandler.Get)
+			r.With(read).Get("/plan", runHandler.GetPlan)
+			r.With(read).Get("/plan-log", runHandler.GetPlanLog)
+			r.With(read).Get("/apply-log", runHandler.GetApplyLog)
+			r.With(read).Get("/logs/stream", runHandler.StreamLogs)
+			r.With(apply).Post("/approve", runHandler.Approve)
+			r.With(queueRun).Post("/discard", runHandler.Discard)
+			r.With(queueRun).Post("/cancel", runHandler.Cancel)
+			r.With(apply).Post("/force-cancel", runHandler.ForceCancel)
+		})
+
+		// TFE API for terraform's cloud block and remote backend
+		r.Route("/api/v2", func(r chi.Router) {
+			r.Get("/organizations", tfeHandler.ListOrganizations)
+			r.Route("/organizations/{orgName}", func(r chi.Router) {
+				r.Get("/", tfeHandler.GetOrganization)
+				r.Get("/entitlement-set", tfeHandler.GetEntitlements)
+				r.Get("/capacity", tfeHandler.GetCapacity)
+				r.Get("/runs/queue", tfeHandler.GetRunQueue)
+				r.Get("/workspaces", tfeHandler.ListWorkspaces)
+				r.Post("/workspaces", tfeHandler.CreateWorkspace)
+				r.Get("/workspaces/{workspaceName}", tfeHandler.GetWorkspaceByName)
+			})
+			r.Route("/workspaces/{workspaceId}", func(r chi.Router) {
+				r.Get("/", tfeHandler.GetWorkspace)
+				r.Post("/actions/lock", tfeHandler.LockWorkspace)
+				r.Post("/actions/unlock", tfeHandler.UnlockWorkspace)
+				r.Post("/actions/force-unlock", tfeHandler.ForceUnlockWorkspace)
+				r.Post("/configuration-versions", tfeHandler.CreateConfigurationVersion)
+				r.Get("/runs", tfeHandler.ListRuns)
+				r.Get("/current-state-version", tfeHandler.GetCurrentStateVersion)
+				r.Get("/current-state-version-outputs", tfeHandler.GetCurrentStateVersionOutputs)
+				r.Post("/state-versions", tfeHandler.CreateStateVersion)
+			})
+			r.Get("/configuration-versions/{cvId}", tfeHandler.GetConfigurationVersion)
+			r.Post("/runs", tfeHandler.CreateRun)
+			r.Route("/runs/{runId}", func(r chi.Router) {
+				r.Get("/", tfeHandler.GetRun)
+				r.Post("/actions/apply", tfeHandler.ApplyRun)
+				r.Post("/actions/discard", tfeHandler.DiscardRun)
+				r.Post("/actions/cancel", tfeHandler.CancelRun)
+			})
+			r.Get("/plans/{runId}", tfeHandler.GetPlan)
+			r.Get("/applies/{runId}", tfeHandler.GetApply)
+			r.Get("/state-versions/{versionId}", tfeHandler.GetStateVersion)
+			r.Get("/state-version-outputs/{outputId}", tfeHandler.GetStateVersionOutput)
+		})
+
+		// Site administration
+		r.Route("/api/admin", func(r chi.Router) {
+			r.Use(middleware.RequireAdmin(cfg))
+			r.Get("/runs/capacity", adminHandler.RunCapacity)
+			r.Put("/organizations/{orgId}/run-limit", adminHandler.SetOrgRunLimit)
+			r.Put("/projects/{projectId}/run-limit", adminHandler.SetProjectRunLimit)
+			r.Post("/state-retention", adminHandler.EnforceStateRetention)
+		})
+	})
+
+	// State HTTP Backend (for terraform remote state). Terraform sends an
+	// API token as the basic auth password; runs read the state of other
+	// workspaces here with their run token.
+	r.Route("/api/state/{workspaceId}", func(r chi.Router) {
+		r.Use(middleware.StateAuthMiddleware(cfg, db))
+		r.Get("/", stateHandler.HTTPBackendGet)
+		r.With(apply).Post("/", stateHandler.HTTPBackendPost)
+		r.Get("/outputs", stateHandler.GetOutputs)
+		r.With(lock).HandleFunc("/lock", stateHandler.HTTPBackendLock)
+		r.With(lock).HandleFunc("/unlock", stateHandler.HTTPBackendUnlock)
+	})
+
+	return r
+}
@@ -1,195 +1,371 @@
-package handlers
-
-import (
-	"encoding/json"
-	"net/http"
-	"time"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/terraconsole/api/internal/middleware"
-	"github.com/terraconsole/api/internal/models"
-	"gorm.io/gorm"
-)
-
-type RunHandler struct {
-	db *gorm.DB
-}
-
-func NewRunHandler(db *gorm.DB) *RunHandler {
-	return &RunHandler{db: db}
-}
-
-func (h *RunHandler) List(w http.ResponseWriter, r *http.Request) {
-	wsID := chi.URLParam(r, "workspaceId")
-
-	var runs []models.Run
-	h.db.Preload("Creator").
-		Where("workspace_id = ?", wsID).
-		Order("created_at DESC").
-		Limit(50).
-		Find(&runs)
-
-	writeJSON(w, http.StatusOK, runs)
-}
-
-func (h *RunHandler) Create(w http.ResponseWriter, r *http.Request) {
-	wsID := chi.URLParam(r, "workspaceId")
-	user := middleware.GetUser(r)
-
-	var req struct {
-		Operation models.RunOperation `json:"operation"`
-		Message   string              `json:"message"`
-		AutoApply bool                `json:"auto_apply"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
-		return
-	}
-
-	// Get workspace to check lock and auto_apply
-	var workspace models.Workspace
-	if err := h.db.First(&workspace, "id = ?", wsID).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
-		return
-	}
-
-	if workspace.Locked {
-		writeJSON(w, http.StatusConflict, map[string]string{"error": "Workspace is locked"})
-		return
-	}
-
-	// Check for pending runs
-	var pendingCount int64
-	h.db.Model(&models.Run{}).Where("workspace_id = ? AND status IN ?", wsID,
-		[]models.RunStatus{models.RunStatusPending, models.RunStatusPlanning, models.RunStatusApplying}).
-		Count(&pendingCount)
-	if pendingCount > 0 {
-		writeJSON(w, http.StatusConflict, map[string]string{"error": "Workspace already has an active run"})
-		return
-	}
-
-	autoApply := req.AutoApply || workspace.AutoApply
-	isDestroy := req.Operation == models.RunOperationDestroy
-
-	run := models.Run{
-		WorkspaceID:      wsID,
-		Status:           models.RunStatusPending,
-		Operation:        req.Operation,
-		Message:          req.Message,
-		IsDestroy:        isDestroy,
-		AutoApply:        autoApply,
-		TerraformVersion: workspace.TerraformVersion,
-		CreatedBy:        user.ID,
-	}
-
-	if err := h.db.Create(&run).Error; err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create run"})
-		return
-	}
-
-	// Load creator
-	h.db.Preload("Creator").First(&run, "id = ?", run.ID)
-
-	writeJSON(w, http.StatusCreated, run)
-}
-
-func (h *RunHandler) Get(w http.ResponseWriter, r *http.Request) {
-	runID := chi.URLParam(r, "runId")
-
-	var run models.Run
-	if err := h.db.Preload("Creator").First(&run, "id = ?", runID).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
-		return
-	}
-
-	writeJSON(w, http.StatusOK, run)
-}
-
-func (h *RunHandler) GetPlanLog(w http.ResponseWriter, r *http.Request) {
-	runID := chi.URLParam(r, "runId")
-
-	var run models.Run
-	if err := h.db.Select("id, plan_log").First(&run, "id = ?", runID).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
-		return
-	}
-
-	writeJSON(w, http.StatusOK, map[string]string{"log": run.PlanLog})
-}
-
-func (h *RunHandler) GetApplyLog(w http.ResponseWriter, r *http.Request) {
-	runID := chi.URLParam(r, "runId")
-
-	var run models.Run
-	if err := h.db.Select("id, apply_log").First(&run, "id = ?", runID).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
-		return
-	}
-
-	writeJSON(w, http.StatusOK, map[string]string{"log": run.ApplyLog})
-}
-
-func (h *RunHandler) Approve(w http.ResponseWriter, r *http.Request) {
-	runID := chi.URLParam(r, "runId")
-
-	var run models.Run
-	if err := h.db.First(&run, "id = ?", runID).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
-		return
-	}
-
-	if run.Status != models.RunStatusNeedsConfirm {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Run is not awaiting confirmation"})
-		return
-	}
-
-	h.db.Model(&run).Update("status", models.RunStatusApplying)
-
-	writeJSON(w, http.StatusOK, map[string]string{"message": "Run approved, applying..."})
-}
-
-func (h *RunHandler) Discard(w http.ResponseWriter, r *http.Request) {
-	runID := chi.URLParam(r, "runId")
-
-	var run models.Run
-	if err := h.db.First(&run, "id = ?", runID).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
-		return
-	}
-
-	if run.Status != models.RunStatusNeedsConfirm && run.Status != models.RunStatusPlanned {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Run cannot be discarded in current state"})
-		return
-	}
-
-	now := time.Now()
-	h.db.Model(&run).Updates(map[string]interface{}{
-		"status":       models.RunStatusDiscarded,
-		"completed_at": &now,
-	})
-
-	writeJSON(w, http.StatusOK, map[string]string{"message": "Run discarded"})
-}
-
-func (h *RunHandler) Cancel(w http.ResponseWriter, r *http.Request) {
-	runID := chi.URLParam(r, "runId")
-
-	var run models.Run
-	if err := h.db.First(&run, "id = ?", runID).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
-		return
-	}
-
-	if run.Status != models.RunStatusPending && run.Status != models.RunStatusPlanning {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Run cannot be cancelled in current state"})
-		return
-	}
-
-	now := time.Now()
-	h.db.Model(&run).Updates(map[string]interface{}{
-		"status":       models.RunStatusCancelled,
-		"completed_at": &now,
-	})
-
-	writeJSON(w, http.StatusOK, map[string]string{"message": "Run cancelled"})
-}
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+	"gorm.io/gorm"
+)
+
+type RunHandler struct {
+	db          *gorm.DB
+	blobs       services.BlobStore
+	executor    *services.RunExecutor
+	upgrader    websocket.Upgrader
+	cancelGrace time.Duration
+}
+
+func NewRunHandler(db *gorm.DB, cfg *config.Config, blobs services.BlobStore, executor *services.RunExecutor) *RunHandler {
+	return &RunHandler{
+		db:          db,
+		blobs:       blobs,
+		executor:    executor,
+		upgrader:    websocket.Upgrader{CheckOrigin: allowedOrigin(cfg)},
+		cancelGrace: time.Duration(cfg.CancelGraceSeconds) * time.Second,
+	}
+}
+
+func (h *RunHandler) List(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	var runs []models.Run
+	h.db.Preload("Creator").
+		Where("workspace_id = ?", wsID).
+		Order("created_at DESC").
+		Limit(50).
+		Find(&runs)
+
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (h *RunHandler) Create(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+	user := middleware.GetUser(r)
+
+	var req struct {
+		Operation models.RunOperation `json:"operation"`
+		Message   string              `json:"message"`
+		AutoApply bool                `json:"auto_apply"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	// Get workspace to check lock and auto_apply
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return
+	}
+
+	if workspace.Locked {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Workspace is locked"})
+		return
+	}
+
+	// Runs of callers who may not apply always wait for confirmation.
+	autoApply := (req.AutoApply || workspace.AutoApply) && middleware.GetAccess(r).Can(models.PermissionApply)
+	isDestroy := req.Operation == models.RunOperationDestroy
+
+	run := models.Run{
+		WorkspaceID:      wsID,
+		Status:           models.RunStatusPending,
+		Operation:        req.Operation,
+		Message:          req.Message,
+		IsDestroy:        isDestroy,
+		AutoApply:        autoApply,
+		TerraformVersion: workspace.TerraformVersion,
+		CreatedBy:        user.ID,
+	}
+
+	if err := h.db.Create(&run).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create run"})
+		return
+	}
+
+	// Runs queue behind the workspace's active run
+	h.executor.Dispatch()
+
+	// Load creator
+	h.db.Preload("Creator").First(&run, "id = ?", run.ID)
+
+	writeJSON(w, http.StatusCreated, run)
+}
+
+func (h *RunHandler) Get(w http.ResponseWriter, r *http.Request) {
+	runID := chi.URLParam(r, "runId")
+
+	var run models.Run
+	if err := h.db.Preload("Creator").First(&run, "id = ?", runID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, run)
+}
+
+func (h *RunHandler) GetPlanLog(w http.ResponseWriter, r *http.Request) {
+	runID := chi.URLParam(r, "runId")
+
+	h.writeLog(w, runID, services.LogPhasePlan)
+}
+
+func (h *RunHandler) GetApplyLog(w http.ResponseWriter, r *http.Request) {
+	runID := chi.URLParam(r, "runId")
+
+	h.writeLog(w, runID, services.LogPhaseApply)
+}
+
+func (h *RunHandler) writeLog(w http.ResponseWriter, runID, phase string) {
+	text, err := services.LoadRunText(h.db, h.blobs, runID, services.LogColumn(phase))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read log"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"log": text})
+}
+
+// GetPlan returns the structured plan as a per-resource change list with
+// sensitive attributes masked.
+func (h *RunHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	runID := chi.URLParam(r, "runId")
+
+	planJSON, err := services.LoadRunText(h.db, h.blobs, runID, "plan_json")
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read plan"})
+		return
+	}
+
+	if planJSON == "" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Plan is not available for this run"})
+		return
+	}
+
+	var plan tfjson.Plan
+	if err := json.Unmarshal([]byte(planJSON), &plan); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to parse plan"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"terraform_version": plan.TerraformVersion,
+		"summary":           services.SummarizePlan(&plan),
+		"resource_changes":  services.PlanResourceChanges(&plan),
+	})
+}
+
+func (h *RunHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	runID := chi.URLParam(r, "runId")
+
+	var run models.Run
+	if err := h.db.First(&run, "id = ?", runID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
+		return
+	}
+
+	if actionErr := h.approve(&run); actionErr != nil {
+		writeJSON(w, actionErr.status, map[string]string{"error": actionErr.message})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Run approved, applying..."})
+}
+
+func (h *RunHandler) Discard(w http.ResponseWriter, r *http.Request) {
+	runID := chi.URLParam(r, "runId")
+
+	var run models.Run
+	if err := h.db.First(&run, "id = ?", runID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
+		return
+	}
+
+	if actionErr := h.discard(&run); actionErr != nil {
+		writeJSON(w, actionErr.status, map[string]string{"error": actionErr.message})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Run discarded"})
+}
+
+func (h *RunHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	runID := chi.URLParam(r, "runId")
+
+	var run models.Run
+	if err := h.db.First(&run, "id = ?", runID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
+		return
+	}
+
+	available, actionErr := h.cancel(&run)
+	if actionErr != nil {
+		writeJSON(w, actionErr.status, map[string]string{"error": actionErr.message})
+		return
+	}
+	if available == nil {
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Run cancelled"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message":                   "Cancel requested, waiting for terraform to stop",
+		"force_cancel_available_at": available,
+	})
+}
+
+// runActionError is why an action on a run was refused, and the status to
+// answer with.
+type runActionError struct {
+	status  int
+	message string
+}
+
+// approve queues the apply of a run awaiting confirmation.
+func (h *RunHandler) approve(run *models.Run) *runActionError {
+	if run.Status != models.RunStatusNeedsConfirm {
+		return &runActionError{http.StatusBadRequest, "Run is not awaiting confirmation"}
+	}
+
+	if err := h.executor.CheckPlanCurrent(run); err != nil {
+		if errors.Is(err, services.ErrStalePlan) {
+			return &runActionError{http.StatusConflict, "Workspace state changed since this run was planned; discard it and queue a new run (" + err.Error() + ")"}
+		}
+		return &runActionError{http.StatusInternalServerError, "Failed to check workspace state"}
+	}
+
+	result := h.db.Model(&models.Run{}).
+		Where("id = ? AND status = ?", run.ID, models.RunStatusNeedsConfirm).
+		Update("status", models.RunStatusApplyQueued)
+	if result.RowsAffected == 0 {
+		return &runActionError{http.StatusConflict, "Run is no longer awaiting confirmation"}
+	}
+	run.Status = models.RunStatusApplyQueued
+
+	h.executor.Dispatch()
+	return nil
+}
+
+func (h *RunHandler) discard(run *models.Run) *runActionError {
+	if run.Status != models.RunStatusNeedsConfirm && run.Status != models.RunStatusPlanned {
+		return &runActionError{http.StatusBadRequest, "Run cannot be discarded in current state"}
+	}
+
+	// A concurrent approval may have queued the apply meanwhile; its plan
+	// must not be cleaned up under it.
+	now := time.Now()
+	result := h.db.Model(&models.Run{}).
+		Where("id = ? AND status IN ?", run.ID, []models.RunStatus{models.RunStatusNeedsConfirm, models.RunStatusPlanned}).
+		Updates(map[string]interface{}{
+			"status":       models.RunStatusDiscarded,
+			"completed_at": &now,
+		})
+	if result.Error != nil {
+		return &runActionError{http.StatusInternalServerError, "Failed to discard run"}
+	}
+	if result.RowsAffected == 0 {
+		return &runActionError{http.StatusConflict, "Run state changed, please retry"}
+	}
+	h.executor.Cleanup(run)
+	return nil
+}
+
+// cancel cancels a queued run at once. For a running one it asks terraform
+// to stop and returns when force-cancel becomes available.
+func (h *RunHandler) cancel(run *models.Run) (*time.Time, *runActionError) {
+	switch run.Status {
+	case models.RunStatusPending, models.RunStatusApplyQueued:
+		if !h.finishCancel(run) {
+			return nil, &runActionError{http.StatusConflict, "Run state changed, please retry"}
+		}
+		return nil, nil
+	case models.RunStatusPlanning, models.RunStatusPlanned, models.RunStatusApplying:
+		now := time.Now()
+		available := now.Add(h.cancelGrace)
+		h.db.Model(run).Updates(map[string]interface{}{
+			"cancel_requested_at":       &now,
+			"force_cancel_available_at": &available,
+		})
+
+		// Terraform is asked to stop and the executor records the run as
+		// cancelled once it has. A run no worker is executing anymore is
+		// finished here.
+		if !h.executor.Cancel(run) {
+			h.finishCancel(run)
+			return nil, nil
+		}
+		return &available, nil
+	default:
+		return nil, &runActionError{http.StatusBadRequest, "Run cannot be cancelled in current state"}
+	}
+}
+
+// ForceCancel kills terraform for a run that did not stop after a cancel.
+// It is only available once the grace period of the cancel has passed.
+func (h *RunHandler) ForceCancel(w http.ResponseWriter, r *http.Request) {
+	runID := chi.URLParam(r, "runId")
+
+	var run models.Run
+	if err := h.db.First(&run, "id = ?", runID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
+		return
+	}
+
+	switch run.Status {
+	case models.RunStatusPlanning, models.RunStatusPlanned, models.RunStatusApplying:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Run is not in progress"})
+		return
+	}
+
+	if run.CancelRequestedAt == nil || run.ForceCancelAvailableAt == nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Run must be cancelled before it can be force-cancelled"})
+		return
+	}
+	if time.Now().Before(*run.ForceCancelAvailableAt) {
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":                     "Force-cancel is not available yet",
+			"force_cancel_available_at": run.ForceCancelAvailableAt,
+		})
+		return
+	}
+
+	if !h.executor.ForceCancel(&run) {
+		h.finishCancel(&run)
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Run cancelled"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"message": "Terraform killed, run is being cancelled"})
+}
+
+// finishCancel marks a run that is not executing as cancelled, unless it
+// moved on in the meantime.
+func (h *RunHandler) finishCancel(run *models.Run) bool {
+	now := time.Now()
+	result := h.db.Model(&models.Run{}).Where("id = ? AND status = ?", run.ID, run.Status).Updates(map[string]interface{}{
+		"status":       models.RunStatusCancelled,
+		"completed_at": &now,
+	})
+	if result.RowsAffected == 0 {
+		return false
+	}
+	h.executor.Cleanup(run)
+	return true
+}
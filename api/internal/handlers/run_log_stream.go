@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+)
+
+const (
+	logStreamPollInterval = time.Second
+	logStreamKeepalive    = 15 * time.Second
+)
+
+// logEvent is a single message on a log stream. Offset is the byte offset
+// just past the data, so a client resumes by passing the last offset it saw.
+type logEvent struct {
+	Type   string           `json:"type"`
+	Phase  string           `json:"phase"`
+	Offset int              `json:"offset"`
+	Data   string           `json:"data,omitempty"`
+	Status models.RunStatus `json:"status,omitempty"`
+}
+
+// StreamLogs tails the plan or apply log of a run while terraform runs.
+// WebSocket clients receive JSON messages; other clients get Server-Sent
+// Events. Query parameters: phase (plan|apply, default plan) and offset
+// (bytes already received). SSE clients may resume with Last-Event-ID.
+func (h *RunHandler) StreamLogs(w http.ResponseWriter, r *http.Request) {
+	runID := chi.URLParam(r, "runId")
+
+	var run models.Run
+	if err := h.db.Select("id").First(&run, "id = ?", runID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Run not found"})
+		return
+	}
+
+	phase := r.URL.Query().Get("phase")
+	if phase == "" {
+		phase = services.LogPhasePlan
+	}
+	if phase != services.LogPhasePlan && phase != services.LogPhaseApply {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "phase must be plan or apply"})
+		return
+	}
+
+	offsetParam := r.URL.Query().Get("offset")
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		offsetParam = lastID
+	}
+	offset := 0
+	if offsetParam != "" {
+		o, err := strconv.Atoi(offsetParam)
+		if err != nil || o < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid offset"})
+			return
+		}
+		offset = o
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.streamWebSocket(w, r, runID, phase, offset)
+		return
+	}
+	h.streamSSE(w, r, runID, phase, offset)
+}
+
+func (h *RunHandler) streamWebSocket(w http.ResponseWriter, r *http.Request, runID, phase string, offset int) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Drain incoming frames so control messages are handled and a closed
+	// connection stops the stream.
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	err = h.followLog(ctx, runID, phase, offset,
+		func(ev logEvent) error {
+			return conn.WriteJSON(ev)
+		},
+		func() error {
+			return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+		},
+	)
+	if err == nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}
+}
+
+func (h *RunHandler) streamSSE(w http.ResponseWriter, r *http.Request, runID, phase string, offset int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Streaming not supported"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	h.followLog(r.Context(), runID, phase, offset,
+		func(ev logEvent) error {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", ev.Type, ev.Offset, data); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		},
+		func() error {
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		},
+	)
+}
+
+// followLog sends log output from offset until the phase has finished, then
+// sends an end event carrying the run status. Output comes from the executor
+// while the phase runs in this process and from storage otherwise.
+func (h *RunHandler) followLog(ctx context.Context, runID, phase string, offset int, send func(logEvent) error, keepalive func() error) error {
+	poll := time.NewTicker(logStreamPollInterval)
+	defer poll.Stop()
+	ping := time.NewTicker(logStreamKeepalive)
+	defer ping.Stop()
+
+	for {
+		// The status is read before the log: the executor persists the final
+		// output before moving the run on, so a finished phase is complete.
+		var run models.Run
+		if err := h.db.Select("id, status").First(&run, "id = ?", runID).Error; err != nil {
+			return err
+		}
+		done := logPhaseDone(run.Status, phase)
+
+		var chunk []byte
+		var updated <-chan struct{}
+		if live := h.executor.LiveLog(runID, phase); live != nil {
+			chunk, updated = live.Since(offset)
+		} else {
+			text, err := services.LoadRunText(h.db, h.blobs, runID, services.LogColumn(phase))
+			if err != nil {
+				return err
+			}
+			if offset < len(text) {
+				chunk = []byte(text[offset:])
+			}
+		}
+
+		if !done {
+			chunk = trimPartialRune(chunk)
+		}
+		if len(chunk) > 0 {
+			offset += len(chunk)
+			if err := send(logEvent{Type: "log", Phase: phase, Offset: offset, Data: string(chunk)}); err != nil {
+				return err
+			}
+		}
+
+		if done {
+			return send(logEvent{Type: "end", Phase: phase, Offset: offset, Status: run.Status})
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-updated:
+		case <-poll.C:
+		case <-ping.C:
+			if err := keepalive(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// logPhaseDone reports whether a phase's log can no longer grow.
+func logPhaseDone(status models.RunStatus, phase string) bool {
+	switch status {
+	case models.RunStatusPending, models.RunStatusPlanning:
+		return false
+	case models.RunStatusPlanned, models.RunStatusNeedsConfirm, models.RunStatusApplyQueued, models.RunStatusApplying:
+		return phase == services.LogPhasePlan
+	default:
+		return true
+	}
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of a chunk so
+// that byte offsets survive the JSON encoding of the data.
+func trimPartialRune(b []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+			break
+		}
+	}
+	return b
+}
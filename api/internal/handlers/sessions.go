@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+)
+
+// maxUserAgentLength bounds the user agent stored with a session.
+const maxUserAgentLength = 512
+
+// sessionResponse is a session as listed to its user, marking the one the
+// request was made from.
+type sessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions lists the caller's active sessions, most recently used first.
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if !canManageSessions(w, r) {
+		return
+	}
+
+	var sessions []models.Session
+	h.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", middleware.GetUser(r).ID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions)
+
+	current := middleware.GetSessionID(r)
+	resp := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, sessionResponse{Session: session, Current: session.ID == current})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeSession signs one of the caller's sessions out. Its access tokens
+// stop working at once and its refresh token cannot be used again.
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if !canManageSessions(w, r) {
+		return
+	}
+
+	revoked, err := services.RevokeSessions(h.db, time.Now(), "id = ? AND user_id = ?", chi.URLParam(r, "sessionId"), middleware.GetUser(r).ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke session"})
+		return
+	}
+	if revoked == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Session not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+}
+
+// RevokeAllSessions signs the caller out everywhere, including the session
+// the request was made from. API tokens are not affected.
+func (h *AuthHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if !canManageSessions(w, r) {
+		return
+	}
+
+	revoked, err := services.RevokeSessions(h.db, time.Now(), "user_id = ?", middleware.GetUser(r).ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke sessions"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"revoked": revoked})
+}
+
+// Logout revokes the session the request was made from.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID := middleware.GetSessionID(r)
+	if sessionID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Not signed in with a session"})
+		return
+	}
+
+	if _, err := services.RevokeSessions(h.db, time.Now(), "id = ?", sessionID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to log out"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
+}
+
+// canManageSessions keeps organization and team tokens, which act for the
+// admin who created them, from signing that admin out.
+func canManageSessions(w http.ResponseWriter, r *http.Request) bool {
+	if token := middleware.GetAPIToken(r); token != nil && token.Kind != models.APITokenUser {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Organization and team tokens cannot manage sessions"})
+		return false
+	}
+	return true
+}
+
+// sessionClient describes the device a request came from.
+func sessionClient(r *http.Request) services.SessionClient {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return services.SessionClient{UserAgent: userAgent, IPAddress: clientIP(r)}
+}
+
+// clientIP is the address of the client without its port. RealIP has
+// already replaced the address with the proxy's X-Real-IP when there is one.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
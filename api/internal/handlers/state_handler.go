@@ -1,170 +1,641 @@
-package handlers
-
-import (
-	"crypto/sha256"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/terraconsole/api/internal/middleware"
-	"github.com/terraconsole/api/internal/models"
-	"github.com/terraconsole/api/internal/services"
-	"gorm.io/gorm"
-)
-
-type StateHandler struct {
-	db        *gorm.DB
-	encryptor *services.EncryptionService
-}
-
-func NewStateHandler(db *gorm.DB, enc *services.EncryptionService) *StateHandler {
-	return &StateHandler{db: db, encryptor: enc}
-}
-
-func (h *StateHandler) GetCurrentState(w http.ResponseWriter, r *http.Request) {
-	wsID := chi.URLParam(r, "workspaceId")
-
-	var state models.StateVersion
-	if err := h.db.Where("workspace_id = ?", wsID).Order("serial DESC").First(&state).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No state found"})
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(state.State)
-}
-
-func (h *StateHandler) ListStateVersions(w http.ResponseWriter, r *http.Request) {
-	wsID := chi.URLParam(r, "workspaceId")
-
-	var versions []models.StateVersion
-	h.db.Select("id, workspace_id, run_id, serial, lineage, state_hash, outputs, resource_count, created_at, created_by").
-		Where("workspace_id = ?", wsID).
-		Order("serial DESC").
-		Limit(50).
-		Find(&versions)
-
-	writeJSON(w, http.StatusOK, versions)
-}
-
-func (h *StateHandler) GetStateVersion(w http.ResponseWriter, r *http.Request) {
-	versionID := chi.URLParam(r, "versionId")
-
-	var state models.StateVersion
-	if err := h.db.First(&state, "id = ?", versionID).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "State version not found"})
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(state.State)
-}
-
-func (h *StateHandler) GetOutputs(w http.ResponseWriter, r *http.Request) {
-	wsID := chi.URLParam(r, "workspaceId")
-
-	var state models.StateVersion
-	if err := h.db.Select("id, outputs").Where("workspace_id = ?", wsID).Order("serial DESC").First(&state).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No state found"})
-		return
-	}
-
-	if state.Outputs == "" {
-		writeJSON(w, http.StatusOK, map[string]interface{}{})
-		return
-	}
-
-	var outputs interface{}
-	json.Unmarshal([]byte(state.Outputs), &outputs)
-	writeJSON(w, http.StatusOK, outputs)
-}
-
-// HTTP Backend for Terraform state
-// These endpoints implement the Terraform HTTP backend protocol
-
-func (h *StateHandler) HTTPBackendGet(w http.ResponseWriter, r *http.Request) {
-	wsID := chi.URLParam(r, "workspaceId")
-
-	var state models.StateVersion
-	if err := h.db.Where("workspace_id = ?", wsID).Order("serial DESC").First(&state).Error; err != nil {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(state.State)
-}
-
-func (h *StateHandler) HTTPBackendPost(w http.ResponseWriter, r *http.Request) {
-	wsID := chi.URLParam(r, "workspaceId")
-	user := middleware.GetUser(r)
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read body"})
-		return
-	}
-
-	// Parse to get serial and lineage
-	var stateData struct {
-		Serial  int    `json:"serial"`
-		Lineage string `json:"lineage"`
-		Outputs map[string]interface{} `json:"outputs"`
-	}
-	json.Unmarshal(body, &stateData)
-
-	hash := fmt.Sprintf("%x", sha256.Sum256(body))
-
-	outputsJSON, _ := json.Marshal(stateData.Outputs)
-
-	state := models.StateVersion{
-		WorkspaceID:   wsID,
-		Serial:        stateData.Serial,
-		Lineage:       stateData.Lineage,
-		State:         body,
-		StateHash:     hash,
-		Outputs:       string(outputsJSON),
-		ResourceCount: 0,
-	}
-
-	if user != nil {
-		state.CreatedBy = user.ID
-	}
-
-	if err := h.db.Create(&state).Error; err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to save state"})
-		return
-	}
-
-	// Update workspace current state
-	h.db.Model(&models.Workspace{}).Where("id = ?", wsID).Update("current_state_id", state.ID)
-
-	w.WriteHeader(http.StatusOK)
-}
-
-func (h *StateHandler) HTTPBackendLock(w http.ResponseWriter, r *http.Request) {
-	wsID := chi.URLParam(r, "workspaceId")
-
-	var workspace models.Workspace
-	if err := h.db.First(&workspace, "id = ?", wsID).Error; err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
-		return
-	}
-
-	if workspace.Locked {
-		writeJSON(w, http.StatusConflict, map[string]string{"error": "Workspace is locked"})
-		return
-	}
-
-	h.db.Model(&workspace).Update("locked", true)
-	writeJSON(w, http.StatusOK, map[string]string{"status": "locked"})
-}
-
-func (h *StateHandler) HTTPBackendUnlock(w http.ResponseWriter, r *http.Request) {
-	wsID := chi.URLParam(r, "workspaceId")
-
-	h.db.Model(&models.Workspace{}).Where("id = ?", wsID).Update("locked", false)
-	writeJSON(w, http.StatusOK, map[string]string{"status": "unlocked"})
-}
+package handlers
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+	"gorm.io/gorm"
+)
+
+type StateHandler struct {
+	db        *gorm.DB
+	encryptor *services.EncryptionService
+	states    *services.StateService
+}
+
+func NewStateHandler(db *gorm.DB, enc *services.EncryptionService, states *services.StateService) *StateHandler {
+	return &StateHandler{db: db, encryptor: enc, states: states}
+}
+
+func (h *StateHandler) GetCurrentState(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	state, err := services.CurrentState(h.db, wsID)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No state found"})
+		return
+	}
+
+	h.writeState(w, state)
+}
+
+func (h *StateHandler) ListStateVersions(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	var versions []models.StateVersion
+	h.db.Select("id, workspace_id, run_id, serial, lineage, state_hash, state_md5, outputs, resource_count, created_at, created_by").
+		Where("workspace_id = ?", wsID).
+		Order("serial DESC").
+		Limit(50).
+		Find(&versions)
+
+	for i := range versions {
+		if outputs, err := services.ParseOutputs(versions[i].Outputs); err == nil {
+			masked, _ := json.Marshal(services.MaskOutputs(outputs))
+			versions[i].Outputs = string(masked)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, versions)
+}
+
+func (h *StateHandler) GetStateVersion(w http.ResponseWriter, r *http.Request) {
+	state, err := h.findStateVersion(chi.URLParam(r, "workspaceId"), chi.URLParam(r, "versionId"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "State version not found"})
+		return
+	}
+
+	h.writeState(w, state)
+}
+
+// DiffStateVersions compares two state versions of a workspace, given by ID
+// or serial, and lists the resources and outputs that changed from the first
+// to the second.
+func (h *StateHandler) DiffStateVersions(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	from, err := h.findStateVersion(wsID, chi.URLParam(r, "fromVersion"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "State version not found"})
+		return
+	}
+	to, err := h.findStateVersion(wsID, chi.URLParam(r, "toVersion"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "State version not found"})
+		return
+	}
+
+	fromData, err := h.states.Data(from)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read state"})
+		return
+	}
+	toData, err := h.states.Data(to)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read state"})
+		return
+	}
+
+	diff, err := services.DiffStates(fromData, toData)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Failed to parse state: " + err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":     stateVersionRef(from),
+		"to":       stateVersionRef(to),
+		"added":    diff.Added,
+		"removed":  diff.Removed,
+		"modified": diff.Modified,
+		"outputs":  diff.Outputs,
+	})
+}
+
+// UploadStateVersion registers an uploaded terraform.tfstate, e.g. from a
+// local or S3 backend being migrated, as the workspace's current state. The
+// state is sent base64-encoded along with its MD5 and/or SHA256 checksum,
+// which must match. Force accepts state of a new lineage.
+func (h *StateHandler) UploadStateVersion(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+	user := middleware.GetUser(r)
+
+	var req struct {
+		State  string `json:"state"`
+		MD5    string `json:"md5"`
+		SHA256 string `json:"sha256"`
+		Force  bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	body, err := base64.StdEncoding.DecodeString(req.State)
+	if err != nil || len(body) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "State must be a base64-encoded state file"})
+		return
+	}
+
+	if req.MD5 == "" && req.SHA256 == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "An md5 or sha256 checksum of the state is required"})
+		return
+	}
+	if req.MD5 != "" && !strings.EqualFold(req.MD5, fmt.Sprintf("%x", md5.Sum(body))) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "MD5 checksum does not match the uploaded state"})
+		return
+	}
+	if req.SHA256 != "" && !strings.EqualFold(req.SHA256, fmt.Sprintf("%x", sha256.Sum256(body))) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "SHA256 checksum does not match the uploaded state"})
+		return
+	}
+
+	var workspace models.Workspace
+	if err := h.db.Preload("Project").First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return
+	}
+	if workspace.Locked && (workspace.LockedBy == nil || *workspace.LockedBy != user.ID) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Workspace is locked by someone else"})
+		return
+	}
+
+	state, err := h.states.Save(body, services.StateWrite{
+		WorkspaceID: wsID,
+		CreatedBy:   user.ID,
+		Force:       req.Force,
+	})
+	if err != nil {
+		writeStateError(w, err)
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: workspace.Project.OrganizationID,
+		Action:         "state.upload",
+		ResourceType:   "workspace",
+		ResourceID:     workspace.ID,
+		ResourceName:   workspace.Name,
+	}, map[string]interface{}{
+		"version_id": state.ID,
+		"serial":     state.Serial,
+		"lineage":    state.Lineage,
+		"force":      req.Force,
+	})
+
+	writeJSON(w, http.StatusCreated, state)
+}
+
+// Rollback makes a copy of a historical state version the current state.
+// The caller must hold the workspace lock, which keeps terraform clients and
+// new runs out, and the workspace may have no run in progress, which could
+// still apply and write state. A reason is required and audited.
+func (h *StateHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+	versionID := chi.URLParam(r, "versionId")
+	user := middleware.GetUser(r)
+
+	var req struct {
+		Reason string `json:"reason"`
+		Force  bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+	if req.Reason == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Reason is required"})
+		return
+	}
+
+	var workspace models.Workspace
+	if err := h.db.Preload("Project").First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return
+	}
+	if !workspace.Locked || workspace.LockedBy == nil || *workspace.LockedBy != user.ID {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Lock the workspace before rolling back its state"})
+		return
+	}
+
+	var active int64
+	err := h.db.Model(&models.Run{}).
+		Where("workspace_id = ? AND status IN ?", wsID, []models.RunStatus{
+			models.RunStatusPlanning,
+			models.RunStatusPlanned,
+			models.RunStatusNeedsConfirm,
+			models.RunStatusApplyQueued,
+			models.RunStatusApplying,
+		}).
+		Count(&active).Error
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to check runs"})
+		return
+	}
+	if active > 0 {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Finish, cancel or discard the workspace's active run before rolling back its state"})
+		return
+	}
+
+	var target models.StateVersion
+	if err := h.db.First(&target, "id = ? AND workspace_id = ?", versionID, wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "State version not found"})
+		return
+	}
+
+	state, err := h.states.Rollback(&target, user.ID, req.Force)
+	if err != nil {
+		writeStateError(w, err)
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: workspace.Project.OrganizationID,
+		Action:         "state.rollback",
+		ResourceType:   "workspace",
+		ResourceID:     workspace.ID,
+		ResourceName:   workspace.Name,
+	}, map[string]interface{}{
+		"reason":              req.Reason,
+		"restored_version_id": target.ID,
+		"restored_serial":     target.Serial,
+		"new_version_id":      state.ID,
+		"new_serial":          state.Serial,
+		"force":               req.Force,
+	})
+
+	writeJSON(w, http.StatusCreated, state)
+}
+
+// findStateVersion looks up a state version of a workspace by ID or, for a
+// number, by serial. A serial written more than once, e.g. after a forced
+// lineage change, refers to the latest version with it.
+func (h *StateHandler) findStateVersion(wsID, ref string) (*models.StateVersion, error) {
+	query := h.db.Where("workspace_id = ?", wsID)
+	if serial, err := strconv.Atoi(ref); err == nil {
+		query = query.Where("serial = ?", serial).Order("created_at DESC")
+	} else {
+		query = query.Where("id = ?", ref)
+	}
+
+	var state models.StateVersion
+	if err := query.First(&state).Error; err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func stateVersionRef(state *models.StateVersion) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         state.ID,
+		"serial":     state.Serial,
+		"lineage":    state.Lineage,
+		"run_id":     state.RunID,
+		"created_at": state.CreatedAt,
+	}
+}
+
+// GetOutputs returns the outputs of the current state with their types and
+// the values of sensitive outputs masked. Runs of workspaces this workspace
+// shares its state with may read them too.
+func (h *StateHandler) GetOutputs(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	if _, ok := h.requireStateAccess(w, r, wsID, stateAccessOutputs); !ok {
+		return
+	}
+
+	state, err := services.CurrentState(h.db, wsID, "id", "outputs")
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No state found"})
+		return
+	}
+
+	outputs, err := services.ParseOutputs(state.Outputs)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read outputs"})
+		return
+	}
+	writeJSON(w, http.StatusOK, services.MaskOutputs(outputs))
+}
+
+// RevealOutput returns the value of a single output, including a sensitive
+// one. Revealing a sensitive output takes the reveal-outputs permission, and
+// every reveal of one is audited.
+func (h *StateHandler) RevealOutput(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+	name := chi.URLParam(r, "name")
+
+	var workspace models.Workspace
+	if err := h.db.Preload("Project").First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return
+	}
+
+	state, err := services.CurrentState(h.db, wsID, "id", "outputs")
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No state found"})
+		return
+	}
+	outputs, err := services.ParseOutputs(state.Outputs)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read outputs"})
+		return
+	}
+	output, ok := outputs[name]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Output not found"})
+		return
+	}
+
+	if output.Sensitive {
+		if !middleware.Require(h.db, w, r, models.PermissionRevealOutputs) {
+			return
+		}
+		recordAudit(h.db, r, models.AuditLog{
+			OrganizationID: workspace.Project.OrganizationID,
+			Action:         "state.output.reveal",
+			ResourceType:   "workspace",
+			ResourceID:     workspace.ID,
+			ResourceName:   workspace.Name,
+		}, map[string]interface{}{
+			"output":           name,
+			"state_version_id": state.ID,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":      name,
+		"value":     output.Value,
+		"type":      output.Type,
+		"sensitive": output.Sensitive,
+	})
+}
+
+// writeState sends the decrypted state document of a version.
+func (h *StateHandler) writeState(w http.ResponseWriter, state *models.StateVersion) {
+	data, err := h.states.Data(state)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read state"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// HTTP Backend for Terraform state
+// These endpoints implement the Terraform HTTP backend protocol
+
+// HTTPBackendGet returns the current state. Runs of other workspaces, reading
+// it through terraform_remote_state, get its outputs only. Members need
+// reveal-outputs as well as read-state, like for the raw state downloads of
+// the API.
+func (h *StateHandler) HTTPBackendGet(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	access, ok := h.requireStateAccess(w, r, wsID, stateAccessOutputs)
+	if !ok {
+		return
+	}
+	if access == stateAccessFull {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Downloading state requires the reveal-outputs permission"})
+		return
+	}
+
+	state, err := services.CurrentState(h.db, wsID)
+	if err != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if access == stateAccessOutputs {
+		h.writeOutputsOnlyState(w, state)
+		return
+	}
+	h.writeState(w, state)
+}
+
+func (h *StateHandler) HTTPBackendPost(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+	user := middleware.GetUser(r)
+
+	if _, ok := h.requireStateAccess(w, r, wsID, stateAccessFull); !ok {
+		return
+	}
+
+	if !h.checkLockID(w, r, wsID) {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read body"})
+		return
+	}
+
+	write := services.StateWrite{
+		WorkspaceID: wsID,
+		Force:       r.URL.Query().Get("force") == "true",
+	}
+	if user != nil {
+		write.CreatedBy = user.ID
+	}
+
+	if _, err := h.states.Save(body, write); err != nil {
+		writeStateError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeStateError reports a rejected state upload. Terraform prints the
+// status code, so conflicts and invalid state get distinct ones.
+func writeStateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidState):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrStateConflict):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to save state"})
+	}
+}
+
+// HTTPBackendLock takes the state lock for terraform. The body is terraform's
+// lock info, which is stored so that competing clients are told who holds the
+// lock. A conflicting lock is answered with 423 and the holder's lock info.
+func (h *StateHandler) HTTPBackendLock(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+	user := middleware.GetUser(r)
+
+	if _, ok := h.requireStateAccess(w, r, wsID, stateAccessFull); !ok {
+		return
+	}
+
+	var lock models.StateLock
+	if err := json.NewDecoder(r.Body).Decode(&lock); err != nil || lock.ID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Lock info with an ID is required"})
+		return
+	}
+
+	now := time.Now()
+	result := h.db.Model(&models.Workspace{}).Where("id = ? AND locked = ?", wsID, false).Updates(map[string]interface{}{
+		"locked":    true,
+		"locked_by": user.ID,
+		"locked_at": &now,
+		"lock_info": lock,
+	})
+	if result.Error != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to lock workspace"})
+		return
+	}
+	if result.RowsAffected > 0 {
+		writeJSON(w, http.StatusOK, lock)
+		return
+	}
+
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return
+	}
+
+	// Terraform does not retry a lock it holds, but a repeated request for
+	// the same lock is harmless.
+	if workspace.LockInfo != nil && workspace.LockInfo.ID == lock.ID {
+		writeJSON(w, http.StatusOK, workspace.LockInfo)
+		return
+	}
+
+	writeJSON(w, http.StatusLocked, h.currentLock(&workspace))
+}
+
+// HTTPBackendUnlock releases the state lock. The lock info in the body must
+// carry the ID of the held lock; terraform force-unlock sends no lock info and
+// releases whatever lock is held.
+func (h *StateHandler) HTTPBackendUnlock(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "workspaceId")
+
+	if _, ok := h.requireStateAccess(w, r, wsID, stateAccessFull); !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read body"})
+		return
+	}
+
+	var lock models.StateLock
+	force := len(bytes.TrimSpace(body)) == 0
+	if !force {
+		if err := json.Unmarshal(body, &lock); err != nil || lock.ID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Lock info with an ID is required"})
+			return
+		}
+	}
+
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return
+	}
+
+	if !workspace.Locked {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "unlocked"})
+		return
+	}
+
+	// Force-unlocking releases whoever holds the lock.
+	if force && !middleware.Require(h.db, w, r, models.PermissionManageWorkspaces) {
+		return
+	}
+
+	query := h.db.Model(&models.Workspace{}).Where("id = ? AND locked = ?", wsID, true)
+	if !force {
+		if workspace.LockInfo == nil || workspace.LockInfo.ID != lock.ID {
+			writeJSON(w, http.StatusConflict, h.currentLock(&workspace))
+			return
+		}
+		query = query.Where("lock_info->>'id' = ?", lock.ID)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"locked":    false,
+		"locked_by": nil,
+		"locked_at": nil,
+		"lock_info": nil,
+	})
+	if result.Error != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to unlock workspace"})
+		return
+	}
+	if result.RowsAffected == 0 && !force {
+		// The lock changed hands between reading and releasing it.
+		h.db.First(&workspace, "id = ?", wsID)
+		writeJSON(w, http.StatusConflict, h.currentLock(&workspace))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "unlocked"})
+}
+
+// checkLockID enforces the lock ID terraform passes as ?ID= when it writes
+// state under a lock. Writes are refused while someone else holds the lock,
+// and when the writer's lock was released or force-unlocked meanwhile.
+func (h *StateHandler) checkLockID(w http.ResponseWriter, r *http.Request, wsID string) bool {
+	lockID := r.URL.Query().Get("ID")
+
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Workspace not found"})
+		return false
+	}
+
+	if !workspace.Locked {
+		if lockID != "" {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "State lock " + lockID + " is no longer held"})
+			return false
+		}
+		return true
+	}
+
+	if workspace.LockInfo == nil || workspace.LockInfo.ID != lockID {
+		writeJSON(w, http.StatusLocked, h.currentLock(&workspace))
+		return false
+	}
+	return true
+}
+
+// currentLock describes the lock held on a workspace in terraform's lock info
+// format. Workspaces locked from TerraConsole have no terraform lock info, so
+// one is made up from who locked it and when.
+func (h *StateHandler) currentLock(workspace *models.Workspace) *models.StateLock {
+	if workspace.LockInfo != nil {
+		return workspace.LockInfo
+	}
+
+	lock := &models.StateLock{
+		Operation: "lock",
+		Info:      "Workspace locked in TerraConsole",
+	}
+	if workspace.LockedAt != nil {
+		lock.Created = *workspace.LockedAt
+	}
+	if workspace.LockedBy != nil {
+		var user models.User
+		if err := h.db.Select("id, username").First(&user, "id = ?", *workspace.LockedBy).Error; err == nil {
+			lock.Who = user.Username
+		}
+	}
+	return lock
+}
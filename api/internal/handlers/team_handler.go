@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+type TeamHandler struct {
+	db *gorm.DB
+}
+
+func NewTeamHandler(db *gorm.DB) *TeamHandler {
+	return &TeamHandler{db: db}
+}
+
+func (h *TeamHandler) List(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+
+	if _, err := findMember(h.db, r, orgID); err != nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var teams []models.Team
+	h.db.Where("organization_id = ?", orgID).Order("name").Find(&teams)
+	writeJSON(w, http.StatusOK, teams)
+}
+
+func (h *TeamHandler) Create(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+
+	if !hasOrgRole(h.db, r, orgID, models.OrgRoleOwner, models.OrgRoleAdmin) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+	if req.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Name is required"})
+		return
+	}
+
+	team := models.Team{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Description:    req.Description,
+	}
+	if err := h.db.Create(&team).Error; err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "A team with this name already exists"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, team)
+}
+
+// Delete removes a team with its memberships and grants, and revokes its API
+// tokens.
+func (h *TeamHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+	teamID := chi.URLParam(r, "teamId")
+
+	if !hasOrgRole(h.db, r, orgID, models.OrgRoleOwner, models.OrgRoleAdmin) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND organization_id = ?", teamID, orgID).Delete(&models.Team{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("team_id = ?", teamID).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", teamID).Delete(&models.TeamAccess{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("team_id = ?", teamID).Delete(&models.APIToken{}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Team not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete team"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Team deleted"})
+}
+
+// Get returns a team with its members and grants.
+func (h *TeamHandler) Get(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+
+	if _, err := findMember(h.db, r, orgID); err != nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var team models.Team
+	err := h.db.Preload("Members.User").Preload("Access").
+		Where("id = ? AND organization_id = ?", chi.URLParam(r, "teamId"), orgID).
+		First(&team).Error
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Team not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, team)
+}
+
+func (h *TeamHandler) Update(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.manageTeam(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Name is required"})
+			return
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if len(updates) > 0 {
+		if err := h.db.Model(&models.Team{}).Where("id = ?", team.ID).Updates(updates).Error; err != nil {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "A team with this name already exists"})
+			return
+		}
+	}
+
+	h.db.First(team, "id = ?", team.ID)
+	writeJSON(w, http.StatusOK, team)
+}
+
+// AddMember adds a member of the organization to a team.
+func (h *TeamHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.manageTeam(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "user_id is required"})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.OrgMember{}).Where("organization_id = ? AND user_id = ?", team.OrganizationID, req.UserID).Count(&count)
+	if count == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Only members of the organization can join its teams"})
+		return
+	}
+
+	member := models.TeamMember{TeamID: team.ID, UserID: req.UserID}
+	if err := h.db.Create(&member).Error; err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "User is already in the team"})
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: team.OrganizationID,
+		Action:         "team.member.add",
+		ResourceType:   "team",
+		ResourceID:     team.ID,
+		ResourceName:   team.Name,
+	}, map[string]interface{}{"user_id": req.UserID})
+
+	h.db.Preload("User").First(&member, "id = ?", member.ID)
+	writeJSON(w, http.StatusCreated, member)
+}
+
+func (h *TeamHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.manageTeam(w, r)
+	if !ok {
+		return
+	}
+	userID := chi.URLParam(r, "userId")
+
+	result := h.db.Where("team_id = ? AND user_id = ?", team.ID, userID).Delete(&models.TeamMember{})
+	if result.Error != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to remove team member"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "User is not in the team"})
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: team.OrganizationID,
+		Action:         "team.member.remove",
+		ResourceType:   "team",
+		ResourceID:     team.ID,
+		ResourceName:   team.Name,
+	}, map[string]interface{}{"user_id": userID})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Team member removed"})
+}
+
+// GrantAccess grants a team access to a project or a workspace of the
+// organization, replacing what the team had there before.
+func (h *TeamHandler) GrantAccess(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.manageTeam(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ProjectID   *string                `json:"project_id"`
+		WorkspaceID *string                `json:"workspace_id"`
+		Access      models.TeamAccessLevel `json:"access"`
+		Permissions []models.Permission    `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+	if (req.ProjectID == nil) == (req.WorkspaceID == nil) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Set exactly one of project_id and workspace_id"})
+		return
+	}
+	if !models.ValidTeamAccessLevel(req.Access) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "access must be read, plan, write, admin or custom"})
+		return
+	}
+
+	grant := models.TeamAccess{TeamID: team.ID, ProjectID: req.ProjectID, WorkspaceID: req.WorkspaceID, Access: req.Access}
+	if req.Access == models.TeamAccessCustom {
+		if len(req.Permissions) == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Custom access needs at least one permission"})
+			return
+		}
+		grant.Permissions = pq.StringArray{}
+		for _, perm := range req.Permissions {
+			if !models.ValidPermission(perm) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Unknown permission " + string(perm)})
+				return
+			}
+			grant.Permissions = append(grant.Permissions, string(perm))
+		}
+	}
+
+	// The project or workspace must belong to the team's organization.
+	target := h.db.Table("projects").Where("projects.organization_id = ? AND projects.deleted_at IS NULL", team.OrganizationID)
+	resourceType, resourceID := "project", ""
+	if req.ProjectID != nil {
+		resourceID = *req.ProjectID
+		target = target.Where("projects.id = ?", resourceID)
+	} else {
+		resourceType, resourceID = "workspace", *req.WorkspaceID
+		target = target.Joins("JOIN workspaces ON workspaces.project_id = projects.id AND workspaces.deleted_at IS NULL").
+			Where("workspaces.id = ?", resourceID)
+	}
+	var count int64
+	if err := target.Count(&count).Error; err != nil || count == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Project or workspace not found"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		existing := tx.Where("team_id = ?", team.ID)
+		if req.ProjectID != nil {
+			existing = existing.Where("project_id = ?", *req.ProjectID)
+		} else {
+			existing = existing.Where("workspace_id = ?", *req.WorkspaceID)
+		}
+		if err := existing.Delete(&models.TeamAccess{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&grant).Error
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to grant access"})
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: team.OrganizationID,
+		Action:         "team.access.grant",
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+	}, map[string]interface{}{"team_id": team.ID, "team": team.Name, "access": grant.Access, "permissions": grant.Permissions})
+	writeJSON(w, http.StatusCreated, grant)
+}
+
+func (h *TeamHandler) RevokeAccess(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.manageTeam(w, r)
+	if !ok {
+		return
+	}
+
+	var grant models.TeamAccess
+	if err := h.db.Where("id = ? AND team_id = ?", chi.URLParam(r, "accessId"), team.ID).First(&grant).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Grant not found"})
+		return
+	}
+	if err := h.db.Delete(&grant).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke access"})
+		return
+	}
+
+	resourceType, resourceID := "project", ""
+	if grant.ProjectID != nil {
+		resourceID = *grant.ProjectID
+	} else if grant.WorkspaceID != nil {
+		resourceType, resourceID = "workspace", *grant.WorkspaceID
+	}
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: team.OrganizationID,
+		Action:         "team.access.revoke",
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+	}, map[string]interface{}{"team_id": team.ID, "team": team.Name, "access": grant.Access})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Access revoked"})
+}
+
+// manageTeam loads the team named by the route for an owner or admin of its
+// organization.
+func (h *TeamHandler) manageTeam(w http.ResponseWriter, r *http.Request) (*models.Team, bool) {
+	orgID := chi.URLParam(r, "orgId")
+
+	if !hasOrgRole(h.db, r, orgID, models.OrgRoleOwner, models.OrgRoleAdmin) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+		return nil, false
+	}
+
+	var team models.Team
+	if err := h.db.Where("id = ? AND organization_id = ?", chi.URLParam(r, "teamId"), orgID).First(&team).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Team not found"})
+		return nil, false
+	}
+	return &team, true
+}
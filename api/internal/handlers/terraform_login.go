@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// terraformLoginClient is the OAuth client ID terraform login uses.
+	terraformLoginClient = "terraform-cli"
+	// terraformLoginPorts is the range of local ports terraform login may
+	// listen on for the redirect.
+	terraformLoginMinPort = 10000
+	terraformLoginMaxPort = 10010
+	oauthCodeTTL          = 5 * time.Minute
+)
+
+// TerraformLoginHandler implements service discovery and the OAuth
+// authorization code flow behind terraform login.
+type TerraformLoginHandler struct {
+	db *gorm.DB
+}
+
+func NewTerraformLoginHandler(db *gorm.DB) *TerraformLoginHandler {
+	return &TerraformLoginHandler{db: db}
+}
+
+// ServiceDiscovery serves /.well-known/terraform.json, which tells terraform
+// how to log in and where the TFE API of this host is.
+func (h *TerraformLoginHandler) ServiceDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"login.v1": map[string]interface{}{
+			"client":      terraformLoginClient,
+			"grant_types": []string{"authz_code"},
+			"authz":       "/oauth/authorize",
+			"token":       "/api/oauth/token",
+			"ports":       []int{terraformLoginMinPort, terraformLoginMaxPort},
+		},
+		"tfe.v2":   "/api/v2/",
+		"tfe.v2.1": "/api/v2/",
+		"tfe.v2.2": "/api/v2/",
+	})
+}
+
+// Authorize issues an authorization code once the signed-in user approved
+// terraform login in the UI, and returns the URL to send the browser back
+// to terraform with.
+func (h *TerraformLoginHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	if middleware.GetAPIToken(r) != nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Sign in to authorize terraform login"})
+		return
+	}
+
+	var req struct {
+		ClientID            string `json:"client_id"`
+		RedirectURI         string `json:"redirect_uri"`
+		ResponseType        string `json:"response_type"`
+		State               string `json:"state"`
+		CodeChallenge       string `json:"code_challenge"`
+		CodeChallengeMethod string `json:"code_challenge_method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+	if req.ClientID != terraformLoginClient {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Unknown client"})
+		return
+	}
+	if req.ResponseType != "code" || req.CodeChallengeMethod != "S256" || req.CodeChallenge == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Only the authorization code flow with an S256 code challenge is supported"})
+		return
+	}
+	redirect, ok := loginRedirectURI(req.RedirectURI)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Redirect URI must be terraform's local login listener"})
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate code"})
+		return
+	}
+	code := hex.EncodeToString(b)
+
+	oauthCode := models.OAuthCode{
+		CodeHash:      services.HashAPIToken(code),
+		UserID:        middleware.GetUser(r).ID,
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeTTL),
+	}
+	if err := h.db.Create(&oauthCode).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create code"})
+		return
+	}
+
+	query := redirect.Query()
+	query.Set("code", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	redirect.RawQuery = query.Encode()
+	writeJSON(w, http.StatusOK, map[string]string{"redirect_url": redirect.String()})
+}
+
+// Token exchanges an authorization code for an API token, which terraform
+// stores as its credentials for this host.
+func (h *TerraformLoginHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, "invalid_request", "Malformed form body")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		oauthError(w, "unsupported_grant_type", "Only authorization_code is supported")
+		return
+	}
+
+	// Codes are single use: the code is deleted as it is redeemed.
+	var codes []models.OAuthCode
+	if err := h.db.Clauses(clause.Returning{}).
+		Where("code_hash = ?", services.HashAPIToken(r.PostForm.Get("code"))).
+		Delete(&codes).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	if len(codes) == 0 {
+		oauthError(w, "invalid_grant", "Unknown or already used code")
+		return
+	}
+	code := codes[0]
+
+	if time.Now().After(code.ExpiresAt) {
+		oauthError(w, "invalid_grant", "Code expired")
+		return
+	}
+	if r.PostForm.Get("client_id") != code.ClientID || r.PostForm.Get("redirect_uri") != code.RedirectURI {
+		oauthError(w, "invalid_grant", "Code was issued to another client")
+		return
+	}
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != code.CodeChallenge {
+		oauthError(w, "invalid_grant", "Code verifier does not match")
+		return
+	}
+
+	secret, hash, err := services.NewAPIToken()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	token := models.APIToken{
+		UserID:      code.UserID,
+		Kind:        models.APITokenUser,
+		Description: "terraform login " + time.Now().UTC().Format("2006-01-02 15:04"),
+		TokenHash:   hash,
+	}
+	if err := h.db.Create(&token).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": secret,
+		"token_type":   "bearer",
+	})
+}
+
+// loginRedirectURI accepts only the listener terraform login starts on
+// localhost, so codes cannot be sent anywhere else.
+func loginRedirectURI(raw string) (*url.URL, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "http" || u.User != nil {
+		return nil, false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+	default:
+		return nil, false
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil || port < terraformLoginMinPort || port > terraformLoginMaxPort {
+		return nil, false
+	}
+	return u, true
+}
+
+func oauthError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
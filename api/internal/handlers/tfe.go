@@ -0,0 +1,660 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+	"gorm.io/gorm"
+)
+
+// tfeAPIVersion is the version of the TFE API this server implements enough
+// of for terraform's cloud and remote backends.
+const tfeAPIVersion = "2.5"
+
+// defaultProjectName is the project workspaces created through the TFE API
+// go to when the request names none.
+const defaultProjectName = "Default Project"
+
+// TFEHandler serves the subset of the Terraform Enterprise v2 API that
+// terraform's cloud block and remote backend use: organizations,
+// workspaces, configuration versions, runs, plans, applies and state
+// versions. Organizations are addressed by name, as in TFE.
+type TFEHandler struct {
+	db       *gorm.DB
+	cfg      *config.Config
+	blobs    services.BlobStore
+	states   *services.StateService
+	executor *services.RunExecutor
+	runs     *RunHandler
+}
+
+func NewTFEHandler(db *gorm.DB, cfg *config.Config, blobs services.BlobStore, states *services.StateService, executor *services.RunExecutor, runs *RunHandler) *TFEHandler {
+	return &TFEHandler{db: db, cfg: cfg, blobs: blobs, states: states, executor: executor, runs: runs}
+}
+
+// tfeResource is a JSON:API resource object.
+type tfeResource struct {
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"`
+	Attributes    map[string]interface{} `json:"attributes"`
+	Relationships map[string]interface{} `json:"relationships,omitempty"`
+	Links         map[string]string      `json:"links,omitempty"`
+}
+
+// tfeRelationship references another resource from a relationships member.
+func tfeRelationship(resourceType, id string) map[string]interface{} {
+	return map[string]interface{}{"data": map[string]string{"type": resourceType, "id": id}}
+}
+
+// tfeRelationshipData is a relationship as sent in request documents.
+type tfeRelationshipData struct {
+	Data *struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"data"`
+}
+
+func (d tfeRelationshipData) id() string {
+	if d.Data == nil {
+		return ""
+	}
+	return d.Data.ID
+}
+
+func writeTFE(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeTFEResource(w http.ResponseWriter, status int, resource tfeResource) {
+	writeTFE(w, status, map[string]interface{}{"data": resource})
+}
+
+// writeTFEList writes a collection as a single page.
+func writeTFEList(w http.ResponseWriter, resources []tfeResource) {
+	writeTFE(w, http.StatusOK, map[string]interface{}{
+		"data": resources,
+		"meta": map[string]interface{}{
+			"pagination": map[string]interface{}{
+				"current-page": 1,
+				"prev-page":    nil,
+				"next-page":    nil,
+				"total-pages":  1,
+				"total-count":  len(resources),
+			},
+		},
+	})
+}
+
+// writeTFEError writes a JSON:API error document. Terraform shows the detail
+// to the user.
+func writeTFEError(w http.ResponseWriter, status int, detail string) {
+	writeTFE(w, status, map[string]interface{}{
+		"errors": []map[string]string{{
+			"status": strconv.Itoa(status),
+			"title":  http.StatusText(status),
+			"detail": detail,
+		}},
+	})
+}
+
+// baseURL is the scheme and host the client reached the API on, for the
+// absolute URLs terraform follows to upload configuration and read logs and
+// state.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	return scheme + "://" + r.Host
+}
+
+// signedURL returns an absolute URL to path that is valid without
+// credentials until ttl has passed. Terraform does not authenticate uploads
+// and log and state downloads, and replaces the query of log URLs, so the
+// signature is the last path segment.
+func (h *TFEHandler) signedURL(r *http.Request, path string, ttl time.Duration) string {
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return baseURL(r) + path + "/" + expires + "." + h.signature(path, expires)
+}
+
+func (h *TFEHandler) signature(path, expires string) string {
+	mac := hmac.New(sha256.New, []byte(h.cfg.JWTSecret))
+	mac.Write([]byte(path + "|" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkSignature verifies the signature segment of a signed URL to path and
+// writes the error response if it is invalid or expired.
+func (h *TFEHandler) checkSignature(w http.ResponseWriter, r *http.Request, path string) bool {
+	expires, signature, _ := strings.Cut(chi.URLParam(r, "signature"), ".")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(h.signature(path, expires))) {
+		writeTFEError(w, http.StatusNotFound, "not found")
+		return false
+	}
+	if time.Now().Unix() > unix {
+		writeTFEError(w, http.StatusForbidden, "link expired")
+		return false
+	}
+	return true
+}
+
+// Ping answers terraform's API version check.
+func (h *TFEHandler) Ping(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("TFP-API-Version", tfeAPIVersion)
+	w.Header().Set("TFP-AppName", "TerraConsole")
+	w.Header().Set("X-TFE-Version", "terraconsole")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// organization resolves an organization by name along with the caller's
+// access to it. Organizations the caller is not a member of are not found.
+func (h *TFEHandler) organization(w http.ResponseWriter, r *http.Request) (*models.Organization, *middleware.Access, bool) {
+	var org models.Organization
+	if err := h.db.First(&org, "name = ?", chi.URLParam(r, "orgName")).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "organization not found")
+		return nil, nil, false
+	}
+	access, err := middleware.ResolveAccess(h.db, r, middleware.Scope{
+		OrganizationID: org.ID,
+		ResourceType:   "organization",
+		ResourceID:     org.ID,
+	})
+	if errors.Is(err, middleware.ErrMFARequired) {
+		writeTFEError(w, http.StatusForbidden, tfeMFARequired)
+		return nil, nil, false
+	}
+	if err != nil || access.Member == nil {
+		writeTFEError(w, http.StatusNotFound, "organization not found")
+		return nil, nil, false
+	}
+	return &org, access, true
+}
+
+// tfeMFARequired tells a member of an organization that requires MFA, and who
+// has not enrolled, why they are refused.
+const tfeMFARequired = "this organization requires MFA: enable it in the web UI under Settings"
+
+// workspace loads a workspace by ID along with the caller's access to it,
+// which must include reading it.
+func (h *TFEHandler) workspace(w http.ResponseWriter, r *http.Request, wsID string) (*models.Workspace, *middleware.Access, bool) {
+	var workspace models.Workspace
+	if err := h.db.Preload("Project.Organization").First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "workspace not found")
+		return nil, nil, false
+	}
+	access, err := h.workspaceAccess(r, &workspace)
+	if errors.Is(err, middleware.ErrMFARequired) {
+		writeTFEError(w, http.StatusForbidden, tfeMFARequired)
+		return nil, nil, false
+	}
+	if err != nil || access.Member == nil {
+		writeTFEError(w, http.StatusNotFound, "workspace not found")
+		return nil, nil, false
+	}
+	if !h.authorize(w, r, access, models.PermissionRead) {
+		return nil, nil, false
+	}
+	return &workspace, access, true
+}
+
+// workspaceAccess resolves the caller's access to a workspace, which takes
+// team grants on it and its project into account.
+func (h *TFEHandler) workspaceAccess(r *http.Request, ws *models.Workspace) (*middleware.Access, error) {
+	return middleware.ResolveAccess(h.db, r, middleware.Scope{
+		OrganizationID: ws.Project.OrganizationID,
+		ProjectID:      ws.ProjectID,
+		WorkspaceID:    ws.ID,
+		ResourceType:   "workspace",
+		ResourceID:     ws.ID,
+	})
+}
+
+// authorize checks that the caller has a permission, auditing and answering
+// a denial like Authorize does for the rest of the API.
+func (h *TFEHandler) authorize(w http.ResponseWriter, r *http.Request, access *middleware.Access, perm models.Permission) bool {
+	if access.Can(perm) {
+		return true
+	}
+	middleware.AuditDenied(h.db, r, access, perm)
+	writeTFEError(w, http.StatusForbidden, "access denied: requires the "+string(perm)+" permission")
+	return false
+}
+
+func (h *TFEHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	query := h.db.Where("id IN (?)", h.db.Model(&models.OrgMember{}).Select("organization_id").Where("user_id = ?", middleware.GetUser(r).ID))
+	if token := middleware.GetAPIToken(r); token != nil && token.OrganizationID != nil {
+		query = query.Where("id = ?", *token.OrganizationID)
+	}
+
+	var orgs []models.Organization
+	query.Order("name").Find(&orgs)
+
+	resources := make([]tfeResource, 0, len(orgs))
+	for i := range orgs {
+		resources = append(resources, organizationResource(&orgs[i]))
+	}
+	writeTFEList(w, resources)
+}
+
+func (h *TFEHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+	writeTFEResource(w, http.StatusOK, organizationResource(org))
+}
+
+// GetEntitlements tells terraform that the organization runs remote
+// operations and stores state.
+func (h *TFEHandler) GetEntitlements(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+	writeTFEResource(w, http.StatusOK, tfeResource{
+		ID:   org.Name,
+		Type: "entitlement-sets",
+		Attributes: map[string]interface{}{
+			"operations":              true,
+			"state-storage":           true,
+			"private-module-registry": false,
+			"sentinel":                false,
+			"teams":                   true,
+			"vcs-integrations":        true,
+		},
+	})
+}
+
+func organizationResource(org *models.Organization) tfeResource {
+	return tfeResource{
+		ID:   org.Name,
+		Type: "organizations",
+		Attributes: map[string]interface{}{
+			"name":       org.Name,
+			"email":      org.Email,
+			"created-at": org.CreatedAt,
+		},
+	}
+}
+
+// ListWorkspaces lists the workspaces of an organization the caller may
+// read, optionally those whose name contains search[name]. Workspaces have no
+// tags here, so a search by tags finds none.
+func (h *TFEHandler) ListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+
+	resources := []tfeResource{}
+	if r.URL.Query().Get("search[tags]") != "" {
+		writeTFEList(w, resources)
+		return
+	}
+
+	query := h.db.Preload("Project.Organization").
+		Joins("JOIN projects ON projects.id = workspaces.project_id AND projects.deleted_at IS NULL").
+		Where("projects.organization_id = ?", org.ID)
+	if name := r.URL.Query().Get("search[name]"); name != "" {
+		query = query.Where("workspaces.name ILIKE ?", "%"+escapeLike(name)+"%")
+	}
+
+	var workspaces []models.Workspace
+	if err := query.Order("workspaces.name").Find(&workspaces).Error; err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to list workspaces")
+		return
+	}
+	for i := range workspaces {
+		access, err := h.workspaceAccess(r, &workspaces[i])
+		if err != nil {
+			writeTFEError(w, http.StatusInternalServerError, "failed to list workspaces")
+			return
+		}
+		if access.Can(models.PermissionRead) {
+			resources = append(resources, workspaceResource(&workspaces[i], access))
+		}
+	}
+	writeTFEList(w, resources)
+}
+
+// GetWorkspaceByName finds a workspace of an organization by name. Names are
+// only unique per project here, so a name used in several projects is
+// refused rather than guessed.
+func (h *TFEHandler) GetWorkspaceByName(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+
+	var workspaces []models.Workspace
+	err := h.db.Preload("Project.Organization").
+		Joins("JOIN projects ON projects.id = workspaces.project_id AND projects.deleted_at IS NULL").
+		Where("projects.organization_id = ? AND workspaces.name = ?", org.ID, chi.URLParam(r, "workspaceName")).
+		Limit(2).
+		Find(&workspaces).Error
+	if err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to load workspace")
+		return
+	}
+	switch len(workspaces) {
+	case 0:
+		writeTFEError(w, http.StatusNotFound, "workspace not found")
+	case 1:
+		access, err := h.workspaceAccess(r, &workspaces[0])
+		if err != nil {
+			writeTFEError(w, http.StatusInternalServerError, "failed to load workspace")
+			return
+		}
+		if !h.authorize(w, r, access, models.PermissionRead) {
+			return
+		}
+		writeTFEResource(w, http.StatusOK, workspaceResource(&workspaces[0], access))
+	default:
+		writeTFEError(w, http.StatusUnprocessableEntity, "several projects have a workspace with this name; rename one of them to use it from terraform")
+	}
+}
+
+// CreateWorkspace creates a workspace, as terraform does for a cloud block
+// that names a workspace that does not exist yet.
+func (h *TFEHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	org, access, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Data struct {
+			Attributes struct {
+				Name             string `json:"name"`
+				Description      string `json:"description"`
+				AutoApply        bool   `json:"auto-apply"`
+				TerraformVersion string `json:"terraform-version"`
+				WorkingDirectory string `json:"working-directory"`
+			} `json:"attributes"`
+			Relationships struct {
+				Project tfeRelationshipData `json:"project"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeTFEError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	attrs := req.Data.Attributes
+	if attrs.Name == "" {
+		writeTFEError(w, http.StatusUnprocessableEntity, "name is required")
+		return
+	}
+
+	// Creating a workspace in a given project may be granted on the project;
+	// the Default Project takes the permission organization-wide.
+	var project models.Project
+	if projectID := req.Data.Relationships.Project.id(); projectID != "" {
+		if err := h.db.First(&project, "id = ? AND organization_id = ?", projectID, org.ID).Error; err != nil {
+			writeTFEError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		projectAccess, err := middleware.ResolveAccess(h.db, r, middleware.Scope{
+			OrganizationID: org.ID,
+			ProjectID:      project.ID,
+			ResourceType:   "project",
+			ResourceID:     project.ID,
+		})
+		if err != nil {
+			writeTFEError(w, http.StatusInternalServerError, "failed to check permissions")
+			return
+		}
+		if !h.authorize(w, r, projectAccess, models.PermissionManageWorkspaces) {
+			return
+		}
+	} else {
+		if !h.authorize(w, r, access, models.PermissionManageWorkspaces) {
+			return
+		}
+		err := h.db.Where(models.Project{OrganizationID: org.ID, Name: defaultProjectName}).
+			Attrs(models.Project{Description: "Workspaces created by terraform"}).
+			FirstOrCreate(&project).Error
+		if err != nil {
+			writeTFEError(w, http.StatusInternalServerError, "failed to create project")
+			return
+		}
+	}
+
+	workspace := models.Workspace{
+		Name:             attrs.Name,
+		Description:      attrs.Description,
+		ProjectID:        project.ID,
+		AutoApply:        attrs.AutoApply,
+		TerraformVersion: "latest",
+		WorkingDirectory: ".",
+	}
+	// Terraform asks for its own version; use it if the server has it.
+	if attrs.TerraformVersion != "" && services.InstalledTerraformVersions(h.cfg.TerraformDir)[attrs.TerraformVersion] != "" {
+		workspace.TerraformVersion = attrs.TerraformVersion
+	}
+	if attrs.WorkingDirectory != "" {
+		workspace.WorkingDirectory = attrs.WorkingDirectory
+	}
+
+	if err := h.db.Create(&workspace).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			writeTFEError(w, http.StatusUnprocessableEntity, "a workspace with this name already exists in the project")
+			return
+		}
+		writeTFEError(w, http.StatusInternalServerError, "failed to create workspace")
+		return
+	}
+	project.Organization = *org
+	workspace.Project = project
+
+	access, err := h.workspaceAccess(r, &workspace)
+	if err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to check permissions")
+		return
+	}
+	writeTFEResource(w, http.StatusCreated, workspaceResource(&workspace, access))
+}
+
+func (h *TFEHandler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspace, access, ok := h.workspace(w, r, chi.URLParam(r, "workspaceId"))
+	if !ok {
+		return
+	}
+	writeTFEResource(w, http.StatusOK, workspaceResource(workspace, access))
+}
+
+// LockWorkspace locks a workspace, as terraform does around local
+// operations on its state.
+func (h *TFEHandler) LockWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspace, access, ok := h.workspace(w, r, chi.URLParam(r, "workspaceId"))
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, access, models.PermissionLock) {
+		return
+	}
+
+	now := time.Now()
+	result := h.db.Model(&models.Workspace{}).Where("id = ? AND locked = ?", workspace.ID, false).Updates(map[string]interface{}{
+		"locked":    true,
+		"locked_by": middleware.GetUser(r).ID,
+		"locked_at": &now,
+	})
+	if result.Error != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to lock workspace")
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeTFEError(w, http.StatusConflict, "workspace is already locked")
+		return
+	}
+
+	h.writeWorkspace(w, workspace.ID, access)
+}
+
+// UnlockWorkspace releases the caller's own lock.
+func (h *TFEHandler) UnlockWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspace, access, ok := h.workspace(w, r, chi.URLParam(r, "workspaceId"))
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, access, models.PermissionLock) {
+		return
+	}
+	if !workspace.Locked {
+		writeTFEError(w, http.StatusConflict, "workspace is not locked")
+		return
+	}
+	if workspace.LockedBy == nil || *workspace.LockedBy != middleware.GetUser(r).ID {
+		var holder models.User
+		name := "another user"
+		if workspace.LockedBy != nil && h.db.Select("username").First(&holder, "id = ?", *workspace.LockedBy).Error == nil {
+			name = holder.Username
+		}
+		writeTFEError(w, http.StatusConflict, "workspace is locked by User "+name)
+		return
+	}
+
+	h.unlock(w, workspace.ID, access)
+}
+
+// ForceUnlockWorkspace releases a lock held by anyone, for callers who may
+// manage workspaces.
+func (h *TFEHandler) ForceUnlockWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspace, access, ok := h.workspace(w, r, chi.URLParam(r, "workspaceId"))
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, access, models.PermissionManageWorkspaces) {
+		return
+	}
+	if !workspace.Locked {
+		writeTFEError(w, http.StatusConflict, "workspace is not locked")
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: workspace.Project.OrganizationID,
+		Action:         "workspace.force_unlock",
+		ResourceType:   "workspace",
+		ResourceID:     workspace.ID,
+		ResourceName:   workspace.Name,
+	}, map[string]interface{}{"locked_by": workspace.LockedBy})
+
+	h.unlock(w, workspace.ID, access)
+}
+
+func (h *TFEHandler) unlock(w http.ResponseWriter, wsID string, access *middleware.Access) {
+	err := h.db.Model(&models.Workspace{}).Where("id = ?", wsID).Updates(map[string]interface{}{
+		"locked":    false,
+		"locked_by": nil,
+		"locked_at": nil,
+		"lock_info": nil,
+	}).Error
+	if err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to unlock workspace")
+		return
+	}
+	h.writeWorkspace(w, wsID, access)
+}
+
+func (h *TFEHandler) writeWorkspace(w http.ResponseWriter, wsID string, access *middleware.Access) {
+	var workspace models.Workspace
+	if err := h.db.Preload("Project.Organization").First(&workspace, "id = ?", wsID).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	writeTFEResource(w, http.StatusOK, workspaceResource(&workspace, access))
+}
+
+func workspaceResource(ws *models.Workspace, access *middleware.Access) tfeResource {
+	manage := access.Can(models.PermissionManageWorkspaces)
+
+	executionMode := "remote"
+	if ws.ExecutionMode == models.ExecutionModeAgent {
+		executionMode = "agent"
+	}
+	workingDirectory := ws.WorkingDirectory
+	if workingDirectory == "." {
+		workingDirectory = ""
+	}
+
+	attrs := map[string]interface{}{
+		"name":                          ws.Name,
+		"description":                   ws.Description,
+		"auto-apply":                    ws.AutoApply,
+		"created-at":                    ws.CreatedAt,
+		"updated-at":                    ws.UpdatedAt,
+		"execution-mode":                executionMode,
+		"operations":                    true,
+		"locked":                        ws.Locked,
+		"terraform-version":             ws.TerraformVersion,
+		"working-directory":             workingDirectory,
+		"speculative-enabled":           true,
+		"structured-run-output-enabled": false,
+		"global-remote-state":           ws.RemoteStateSharing == models.RemoteStateSharingOrganization,
+		"actions": map[string]bool{
+			"is-destroyable": manage,
+		},
+		"permissions": map[string]bool{
+			"can-read-settings":         access.Can(models.PermissionRead),
+			"can-read-state-versions":   access.Can(models.PermissionReadState),
+			"can-read-variable":         access.Can(models.PermissionRead),
+			"can-queue-run":             access.Can(models.PermissionQueueRun),
+			"can-queue-apply":           access.Can(models.PermissionApply),
+			"can-queue-destroy":         access.Can(models.PermissionApply),
+			"can-lock":                  access.Can(models.PermissionLock),
+			"can-unlock":                access.Can(models.PermissionLock),
+			"can-create-state-versions": access.Can(models.PermissionApply),
+			"can-update-variable":       access.Can(models.PermissionWriteVariables),
+			"can-force-unlock":          manage,
+			"can-update":                manage,
+			"can-destroy":               manage,
+		},
+	}
+	if ws.VCSRepoURL != "" {
+		attrs["vcs-repo"] = map[string]string{
+			"identifier":          ws.VCSRepoURL,
+			"branch":              ws.VCSBranch,
+			"repository-http-url": ws.VCSRepoURL,
+		}
+	}
+
+	relationships := map[string]interface{}{
+		"organization": tfeRelationship("organizations", ws.Project.Organization.Name),
+		"project":      tfeRelationship("projects", ws.ProjectID),
+	}
+	if ws.CurrentStateID != nil {
+		relationships["current-state-version"] = tfeRelationship("state-versions", *ws.CurrentStateID)
+	}
+
+	return tfeResource{
+		ID:            ws.ID,
+		Type:          "workspaces",
+		Attributes:    attrs,
+		Relationships: relationships,
+	}
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,670 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+)
+
+const (
+	uploadURLTTL = time.Hour
+	logURLTTL    = 24 * time.Hour
+)
+
+// queuedStatuses and executingStatuses split the runs an organization's
+// capacity is reported for.
+var (
+	queuedStatuses    = []models.RunStatus{models.RunStatusPending, models.RunStatusApplyQueued}
+	executingStatuses = []models.RunStatus{models.RunStatusPlanning, models.RunStatusPlanned, models.RunStatusApplying}
+)
+
+// CreateConfigurationVersion starts an upload of configuration for runs of a
+// workspace. Terraform uploads the archive to the returned upload-url.
+func (h *TFEHandler) CreateConfigurationVersion(w http.ResponseWriter, r *http.Request) {
+	workspace, access, ok := h.workspace(w, r, chi.URLParam(r, "workspaceId"))
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, access, models.PermissionQueueRun) {
+		return
+	}
+
+	var req struct {
+		Data struct {
+			Attributes struct {
+				AutoQueueRuns *bool `json:"auto-queue-runs"`
+				Speculative   bool  `json:"speculative"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeTFEError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	cv := models.ConfigurationVersion{
+		WorkspaceID:   workspace.ID,
+		Status:        models.ConfigurationPending,
+		Speculative:   req.Data.Attributes.Speculative,
+		AutoQueueRuns: req.Data.Attributes.AutoQueueRuns == nil || *req.Data.Attributes.AutoQueueRuns,
+		CreatedBy:     middleware.GetUser(r).ID,
+		AutoApply:     workspace.AutoApply && access.Can(models.PermissionApply),
+	}
+	if err := h.db.Create(&cv).Error; err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to create configuration version")
+		return
+	}
+
+	writeTFEResource(w, http.StatusCreated, h.configurationVersionResource(r, &cv))
+}
+
+func (h *TFEHandler) GetConfigurationVersion(w http.ResponseWriter, r *http.Request) {
+	var cv models.ConfigurationVersion
+	if err := h.db.First(&cv, "id = ?", chi.URLParam(r, "cvId")).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "configuration version not found")
+		return
+	}
+	if _, _, ok := h.workspace(w, r, cv.WorkspaceID); !ok {
+		return
+	}
+	writeTFEResource(w, http.StatusOK, h.configurationVersionResource(r, &cv))
+}
+
+// UploadConfiguration receives the configuration archive at a signed
+// upload-url and queues a run of it if the version auto-queues runs.
+func (h *TFEHandler) UploadConfiguration(w http.ResponseWriter, r *http.Request) {
+	cvID := chi.URLParam(r, "cvId")
+	if !h.checkSignature(w, r, "/api/v2/configuration-versions/"+cvID+"/upload") {
+		return
+	}
+
+	var cv models.ConfigurationVersion
+	if err := h.db.First(&cv, "id = ?", cvID).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "configuration version not found")
+		return
+	}
+	if cv.Status != models.ConfigurationPending {
+		writeTFEError(w, http.StatusConflict, "configuration was already uploaded")
+		return
+	}
+
+	archive, err := io.ReadAll(http.MaxBytesReader(w, r.Body, services.MaxConfigurationSize))
+	if err != nil {
+		writeTFEError(w, http.StatusRequestEntityTooLarge, "configuration archive is too large")
+		return
+	}
+	if err := services.CheckConfigurationArchive(archive); err != nil {
+		h.db.Model(&cv).Update("status", models.ConfigurationErrored)
+		writeTFEError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	key, err := h.blobs.Put(archive)
+	if err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to store configuration")
+		return
+	}
+	result := h.db.Model(&models.ConfigurationVersion{}).
+		Where("id = ? AND status = ?", cv.ID, models.ConfigurationPending).
+		Updates(map[string]interface{}{"status": models.ConfigurationUploaded, "archive_blob": key})
+	if result.Error != nil || result.RowsAffected == 0 {
+		writeTFEError(w, http.StatusConflict, "configuration was already uploaded")
+		return
+	}
+	cv.Status = models.ConfigurationUploaded
+
+	if cv.AutoQueueRuns {
+		var workspace models.Workspace
+		if err := h.db.First(&workspace, "id = ?", cv.WorkspaceID).Error; err == nil && !workspace.Locked {
+			operation := models.RunOperationApply
+			if cv.Speculative {
+				operation = models.RunOperationPlan
+			}
+			if _, err := h.queueRun(&workspace, &cv, operation, false, cv.AutoApply, "Triggered by configuration upload", cv.CreatedBy); err != nil {
+				writeTFEError(w, http.StatusInternalServerError, "failed to queue run")
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TFEHandler) configurationVersionResource(r *http.Request, cv *models.ConfigurationVersion) tfeResource {
+	attrs := map[string]interface{}{
+		"status":          cv.Status,
+		"source":          "tfe-api",
+		"speculative":     cv.Speculative,
+		"auto-queue-runs": cv.AutoQueueRuns,
+		"created-at":      cv.CreatedAt,
+	}
+	if cv.Status == models.ConfigurationPending {
+		attrs["upload-url"] = h.signedURL(r, "/api/v2/configuration-versions/"+cv.ID+"/upload", uploadURLTTL)
+	}
+	if cv.Status == models.ConfigurationErrored {
+		attrs["error"] = "invalid_archive"
+		attrs["error-message"] = "The configuration archive could not be read"
+	}
+	return tfeResource{
+		ID:         cv.ID,
+		Type:       "configuration-versions",
+		Attributes: attrs,
+	}
+}
+
+// CreateRun queues a run of an uploaded configuration version.
+func (h *TFEHandler) CreateRun(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Data struct {
+			Attributes struct {
+				Message      string            `json:"message"`
+				IsDestroy    bool              `json:"is-destroy"`
+				PlanOnly     bool              `json:"plan-only"`
+				RefreshOnly  bool              `json:"refresh-only"`
+				Refresh      *bool             `json:"refresh"`
+				AutoApply    *bool             `json:"auto-apply"`
+				TargetAddrs  []string          `json:"target-addrs"`
+				ReplaceAddrs []string          `json:"replace-addrs"`
+				Variables    []json.RawMessage `json:"variables"`
+			} `json:"attributes"`
+			Relationships struct {
+				Workspace            tfeRelationshipData `json:"workspace"`
+				ConfigurationVersion tfeRelationshipData `json:"configuration-version"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeTFEError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	attrs := req.Data.Attributes
+
+	workspace, access, ok := h.workspace(w, r, req.Data.Relationships.Workspace.id())
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, access, models.PermissionQueueRun) {
+		return
+	}
+
+	// Runs here always plan the whole configuration with a refresh, and
+	// take variables from the workspace only.
+	switch {
+	case len(attrs.TargetAddrs) > 0:
+		writeTFEError(w, http.StatusUnprocessableEntity, "resource targeting is not supported")
+		return
+	case len(attrs.ReplaceAddrs) > 0:
+		writeTFEError(w, http.StatusUnprocessableEntity, "-replace is not supported")
+		return
+	case attrs.Refresh != nil && !*attrs.Refresh:
+		writeTFEError(w, http.StatusUnprocessableEntity, "-refresh=false is not supported")
+		return
+	case len(attrs.Variables) > 0:
+		writeTFEError(w, http.StatusUnprocessableEntity, "run variables are not supported; set them on the workspace")
+		return
+	}
+
+	var cv models.ConfigurationVersion
+	if err := h.db.First(&cv, "id = ? AND workspace_id = ?", req.Data.Relationships.ConfigurationVersion.id(), workspace.ID).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "configuration version not found")
+		return
+	}
+	if cv.Status != models.ConfigurationUploaded {
+		writeTFEError(w, http.StatusUnprocessableEntity, "configuration version has not been uploaded")
+		return
+	}
+
+	if workspace.Locked {
+		writeTFEError(w, http.StatusConflict, "workspace is locked")
+		return
+	}
+
+	// A speculative plan may still be a destroy plan, so is-destroy is
+	// kept apart from the operation.
+	operation := models.RunOperationApply
+	switch {
+	case attrs.PlanOnly || cv.Speculative:
+		operation = models.RunOperationPlan
+	case attrs.RefreshOnly:
+		operation = models.RunOperationRefresh
+	case attrs.IsDestroy:
+		operation = models.RunOperationDestroy
+	}
+	autoApply := workspace.AutoApply
+	if attrs.AutoApply != nil {
+		autoApply = *attrs.AutoApply
+	}
+	// Runs of callers who may not apply always wait for confirmation.
+	autoApply = autoApply && access.Can(models.PermissionApply)
+
+	run, err := h.queueRun(workspace, &cv, operation, attrs.IsDestroy, autoApply, attrs.Message, middleware.GetUser(r).ID)
+	if err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to create run")
+		return
+	}
+	writeTFEResource(w, http.StatusCreated, runResource(run, access))
+}
+
+func (h *TFEHandler) queueRun(workspace *models.Workspace, cv *models.ConfigurationVersion, operation models.RunOperation, isDestroy, autoApply bool, message, userID string) (*models.Run, error) {
+	if message == "" {
+		message = "Queued via the API"
+	}
+	run := models.Run{
+		WorkspaceID:            workspace.ID,
+		Status:                 models.RunStatusPending,
+		Operation:              operation,
+		Message:                message,
+		IsDestroy:              isDestroy,
+		AutoApply:              autoApply,
+		TerraformVersion:       workspace.TerraformVersion,
+		ConfigurationVersionID: &cv.ID,
+		CreatedBy:              userID,
+	}
+	if err := h.db.Create(&run).Error; err != nil {
+		return nil, err
+	}
+
+	h.executor.Dispatch()
+	return &run, nil
+}
+
+func (h *TFEHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	run, access, ok := h.run(w, r)
+	if !ok {
+		return
+	}
+	writeTFEResource(w, http.StatusOK, runResource(run, access))
+}
+
+// ListRuns lists the latest runs of a workspace, newest first.
+func (h *TFEHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	workspace, access, ok := h.workspace(w, r, chi.URLParam(r, "workspaceId"))
+	if !ok {
+		return
+	}
+
+	var runs []models.Run
+	h.db.Where("workspace_id = ?", workspace.ID).Order("created_at DESC").Limit(50).Find(&runs)
+
+	resources := make([]tfeResource, 0, len(runs))
+	for i := range runs {
+		resources = append(resources, runResource(&runs[i], access))
+	}
+	writeTFEList(w, resources)
+}
+
+// ApplyRun confirms a run awaiting confirmation.
+func (h *TFEHandler) ApplyRun(w http.ResponseWriter, r *http.Request) {
+	run, access, ok := h.run(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, access, models.PermissionApply) {
+		return
+	}
+	if actionErr := h.runs.approve(run); actionErr != nil {
+		writeTFEError(w, tfeActionStatus(actionErr), actionErr.message)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *TFEHandler) DiscardRun(w http.ResponseWriter, r *http.Request) {
+	run, access, ok := h.run(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, access, models.PermissionQueueRun) {
+		return
+	}
+	if actionErr := h.runs.discard(run); actionErr != nil {
+		writeTFEError(w, tfeActionStatus(actionErr), actionErr.message)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// CancelRun cancels a run, as terraform does when interrupted while it
+// waits for a remote run.
+func (h *TFEHandler) CancelRun(w http.ResponseWriter, r *http.Request) {
+	run, access, ok := h.run(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, access, models.PermissionQueueRun) {
+		return
+	}
+	if _, actionErr := h.runs.cancel(run); actionErr != nil {
+		writeTFEError(w, tfeActionStatus(actionErr), actionErr.message)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// tfeActionStatus answers refused run actions with 409, which is what TFE
+// returns for actions not allowed in the run's current state.
+func tfeActionStatus(actionErr *runActionError) int {
+	if actionErr.status == http.StatusBadRequest {
+		return http.StatusConflict
+	}
+	return actionErr.status
+}
+
+func (h *TFEHandler) run(w http.ResponseWriter, r *http.Request) (*models.Run, *middleware.Access, bool) {
+	var run models.Run
+	if err := h.db.First(&run, "id = ?", chi.URLParam(r, "runId")).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "run not found")
+		return nil, nil, false
+	}
+	_, access, ok := h.workspace(w, r, run.WorkspaceID)
+	return &run, access, ok
+}
+
+// GetPlan and GetApply serve the plan and apply of a run. Both share the
+// run's ID.
+func (h *TFEHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	run, _, ok := h.run(w, r)
+	if !ok {
+		return
+	}
+	writeTFEResource(w, http.StatusOK, h.phaseResource(r, run, services.LogPhasePlan))
+}
+
+func (h *TFEHandler) GetApply(w http.ResponseWriter, r *http.Request) {
+	run, _, ok := h.run(w, r)
+	if !ok {
+		return
+	}
+	writeTFEResource(w, http.StatusOK, h.phaseResource(r, run, services.LogPhaseApply))
+}
+
+func (h *TFEHandler) phaseResource(r *http.Request, run *models.Run, phase string) tfeResource {
+	resourceType, status := "plans", planStatus(run)
+	if phase == services.LogPhaseApply {
+		resourceType, status = "applies", applyStatus(run)
+	}
+	return tfeResource{
+		ID:   run.ID,
+		Type: resourceType,
+		Attributes: map[string]interface{}{
+			"status":                status,
+			"has-changes":           runHasChanges(run),
+			"resource-additions":    run.ResourcesAdded + run.ResourcesReplaced,
+			"resource-changes":      run.ResourcesChanged,
+			"resource-destructions": run.ResourcesDeleted + run.ResourcesReplaced,
+			"resource-imports":      run.ResourcesImported,
+			"log-read-url":          h.signedURL(r, "/api/v2/"+resourceType+"/"+run.ID+"/logs", logURLTTL),
+		},
+	}
+}
+
+// GetPlanLogs and GetApplyLogs serve a phase's log at its signed
+// log-read-url. As in TFE, the log starts with STX and ends with ETX once
+// the phase is over, and is read in chunks with offset and limit.
+func (h *TFEHandler) GetPlanLogs(w http.ResponseWriter, r *http.Request) {
+	h.writeLogs(w, r, "plans", services.LogPhasePlan)
+}
+
+func (h *TFEHandler) GetApplyLogs(w http.ResponseWriter, r *http.Request) {
+	h.writeLogs(w, r, "applies", services.LogPhaseApply)
+}
+
+func (h *TFEHandler) writeLogs(w http.ResponseWriter, r *http.Request, resourceType, phase string) {
+	runID := chi.URLParam(r, "runId")
+	if !h.checkSignature(w, r, "/api/v2/"+resourceType+"/"+runID+"/logs") {
+		return
+	}
+
+	var run models.Run
+	if err := h.db.Select("id", "status", "plan_completed_at", "applied_at").First(&run, "id = ?", runID).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "run not found")
+		return
+	}
+
+	// Read whether the phase is over before its log, so the log is complete
+	// when ETX is added.
+	var done bool
+	if phase == services.LogPhasePlan {
+		done = planStatus(&run) != "pending" && planStatus(&run) != "running"
+	} else {
+		done = applyStatus(&run) != "pending" && applyStatus(&run) != "queued" && applyStatus(&run) != "running"
+	}
+
+	var text string
+	if live := h.executor.LiveLog(runID, phase); live != nil {
+		text = live.String()
+	} else {
+		var err error
+		if text, err = services.LoadRunText(h.db, h.blobs, runID, services.LogColumn(phase)); err != nil {
+			writeTFEError(w, http.StatusInternalServerError, "failed to read log")
+			return
+		}
+	}
+
+	data := "\x02" + text
+	if done {
+		data += "\x03"
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 || offset > len(data) {
+		offset = len(data)
+	}
+	data = data[offset:]
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(data) {
+		data = data[:limit]
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, data)
+}
+
+// GetRunQueue lists the organization's runs waiting for capacity, which
+// terraform shows while its run is pending. Only runs of workspaces the
+// caller may read are listed.
+func (h *TFEHandler) GetRunQueue(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+
+	var runs []models.Run
+	err := h.db.Preload("Workspace.Project").
+		Joins("JOIN workspaces ON workspaces.id = runs.workspace_id").
+		Joins("JOIN projects ON projects.id = workspaces.project_id").
+		Where("projects.organization_id = ? AND runs.status IN ?", org.ID, queuedStatuses).
+		Order("runs.queue_position ASC NULLS LAST, runs.created_at ASC").
+		Find(&runs).Error
+	if err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to list runs")
+		return
+	}
+
+	accesses := make(map[string]*middleware.Access)
+	resources := make([]tfeResource, 0, len(runs))
+	for i := range runs {
+		access, ok := accesses[runs[i].WorkspaceID]
+		if !ok {
+			access, err = h.workspaceAccess(r, &runs[i].Workspace)
+			if err != nil {
+				writeTFEError(w, http.StatusInternalServerError, "failed to list runs")
+				return
+			}
+			accesses[runs[i].WorkspaceID] = access
+		}
+		if access.Can(models.PermissionRead) {
+			resources = append(resources, runResource(&runs[i], access))
+		}
+	}
+	writeTFEList(w, resources)
+}
+
+// GetCapacity reports how many of the organization's runs are queued and
+// executing.
+func (h *TFEHandler) GetCapacity(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+
+	count := func(statuses []models.RunStatus) int64 {
+		var n int64
+		h.db.Model(&models.Run{}).
+			Joins("JOIN workspaces ON workspaces.id = runs.workspace_id").
+			Joins("JOIN projects ON projects.id = workspaces.project_id").
+			Where("projects.organization_id = ? AND runs.status IN ?", org.ID, statuses).
+			Count(&n)
+		return n
+	}
+
+	writeTFEResource(w, http.StatusOK, tfeResource{
+		ID:   org.Name,
+		Type: "organization-capacity",
+		Attributes: map[string]interface{}{
+			"pending": count(queuedStatuses),
+			"running": count(executingStatuses),
+		},
+	})
+}
+
+func runResource(run *models.Run, access *middleware.Access) tfeResource {
+	confirmable := run.Status == models.RunStatusNeedsConfirm
+	discardable := run.Status == models.RunStatusNeedsConfirm || run.Status == models.RunStatusPlanned
+	cancelable := false
+	switch run.Status {
+	case models.RunStatusPending, models.RunStatusApplyQueued, models.RunStatusPlanning, models.RunStatusPlanned, models.RunStatusApplying:
+		cancelable = true
+	}
+
+	attrs := map[string]interface{}{
+		"status":            runStatus(run),
+		"message":           run.Message,
+		"source":            "tfe-api",
+		"is-destroy":        run.IsDestroy,
+		"has-changes":       runHasChanges(run),
+		"auto-apply":        run.AutoApply,
+		"plan-only":         run.Operation == models.RunOperationPlan,
+		"refresh":           true,
+		"refresh-only":      run.Operation == models.RunOperationRefresh,
+		"terraform-version": run.TerraformVersion,
+		"created-at":        run.CreatedAt,
+		"actions": map[string]bool{
+			"is-confirmable":      confirmable,
+			"is-discardable":      discardable,
+			"is-cancelable":       cancelable,
+			"is-force-cancelable": run.ForceCancelAvailableAt != nil && time.Now().After(*run.ForceCancelAvailableAt) && cancelable,
+		},
+		"permissions": map[string]bool{
+			"can-apply":         access.Can(models.PermissionApply),
+			"can-cancel":        access.Can(models.PermissionQueueRun),
+			"can-discard":       access.Can(models.PermissionQueueRun),
+			"can-force-cancel":  access.Can(models.PermissionApply),
+			"can-force-execute": false,
+		},
+		"status-timestamps": map[string]interface{}{
+			"plan-queued-at": run.CreatedAt,
+			"planning-at":    run.StartedAt,
+			"planned-at":     run.PlanCompletedAt,
+			"applied-at":     run.AppliedAt,
+		},
+	}
+	if run.QueuePosition != nil {
+		attrs["position-in-queue"] = *run.QueuePosition
+	}
+
+	relationships := map[string]interface{}{
+		"workspace": tfeRelationship("workspaces", run.WorkspaceID),
+		"plan":      tfeRelationship("plans", run.ID),
+		"apply":     tfeRelationship("applies", run.ID),
+	}
+	if run.ConfigurationVersionID != nil {
+		relationships["configuration-version"] = tfeRelationship("configuration-versions", *run.ConfigurationVersionID)
+	}
+
+	return tfeResource{
+		ID:            run.ID,
+		Type:          "runs",
+		Attributes:    attrs,
+		Relationships: relationships,
+	}
+}
+
+// runStatus maps a run status to TFE's. A planned run here is one that is
+// applied automatically, which TFE reports as queued for apply; a run
+// awaiting confirmation is "planned" in TFE.
+func runStatus(run *models.Run) string {
+	switch run.Status {
+	case models.RunStatusPlanned, models.RunStatusApplyQueued:
+		return "apply_queued"
+	case models.RunStatusNeedsConfirm:
+		return "planned"
+	case models.RunStatusCancelled:
+		return "canceled"
+	default:
+		return string(run.Status)
+	}
+}
+
+// planStatus is the status of a run's plan. Runs that failed or were
+// cancelled before their plan completed failed in the plan.
+func planStatus(run *models.Run) string {
+	switch run.Status {
+	case models.RunStatusPending:
+		return "pending"
+	case models.RunStatusPlanning:
+		return "running"
+	case models.RunStatusErrored:
+		if run.PlanCompletedAt == nil {
+			return "errored"
+		}
+	case models.RunStatusCancelled:
+		if run.PlanCompletedAt == nil {
+			return "canceled"
+		}
+	}
+	return "finished"
+}
+
+func applyStatus(run *models.Run) string {
+	switch run.Status {
+	case models.RunStatusPending, models.RunStatusPlanning, models.RunStatusPlanned, models.RunStatusNeedsConfirm:
+		return "pending"
+	case models.RunStatusApplyQueued:
+		return "queued"
+	case models.RunStatusApplying:
+		return "running"
+	case models.RunStatusApplied:
+		return "finished"
+	case models.RunStatusErrored:
+		if run.PlanCompletedAt != nil {
+			return "errored"
+		}
+	case models.RunStatusCancelled:
+		if run.PlanCompletedAt != nil {
+			return "canceled"
+		}
+	}
+	return "unreachable"
+}
+
+// runHasChanges reports whether a run's plan changes anything. Output-only
+// changes are not counted in the resource totals, but still need
+// confirmation.
+func runHasChanges(run *models.Run) bool {
+	if run.ResourcesAdded+run.ResourcesChanged+run.ResourcesDeleted+run.ResourcesReplaced+run.ResourcesImported > 0 {
+		return true
+	}
+	switch run.Status {
+	case models.RunStatusPlanned, models.RunStatusNeedsConfirm, models.RunStatusApplyQueued, models.RunStatusApplying, models.RunStatusApplied:
+		return true
+	}
+	return false
+}
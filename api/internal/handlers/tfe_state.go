@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+)
+
+const stateURLTTL = time.Hour
+
+// GetCurrentStateVersion returns the workspace's current state version,
+// which terraform downloads the state of.
+func (h *TFEHandler) GetCurrentStateVersion(w http.ResponseWriter, r *http.Request) {
+	workspace, access, ok := h.workspace(w, r, chi.URLParam(r, "workspaceId"))
+	if !ok || !h.authorize(w, r, access, models.PermissionReadState) {
+		return
+	}
+	state, err := services.CurrentState(h.db, workspace.ID, stateVersionColumns...)
+	if err != nil {
+		writeTFEError(w, http.StatusNotFound, "workspace has no state")
+		return
+	}
+	writeTFEResource(w, http.StatusOK, h.stateVersionResource(r, access, state))
+}
+
+func (h *TFEHandler) GetStateVersion(w http.ResponseWriter, r *http.Request) {
+	var state models.StateVersion
+	if err := h.db.Select(stateVersionColumns).First(&state, "id = ?", chi.URLParam(r, "versionId")).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "state version not found")
+		return
+	}
+	_, access, ok := h.workspace(w, r, state.WorkspaceID)
+	if !ok || !h.authorize(w, r, access, models.PermissionReadState) {
+		return
+	}
+	writeTFEResource(w, http.StatusOK, h.stateVersionResource(r, access, &state))
+}
+
+// DownloadStateVersion serves the state document at a signed
+// hosted-state-download-url.
+func (h *TFEHandler) DownloadStateVersion(w http.ResponseWriter, r *http.Request) {
+	versionID := chi.URLParam(r, "versionId")
+	if !h.checkSignature(w, r, "/api/v2/state-versions/"+versionID+"/download") {
+		return
+	}
+
+	var state models.StateVersion
+	if err := h.db.First(&state, "id = ?", versionID).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "state version not found")
+		return
+	}
+	data, err := h.states.Data(&state)
+	if err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to read state")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// CreateStateVersion stores state terraform wrote during a local operation
+// or a state migration. The state is sent inline, base64-encoded, with its
+// MD5. Terraform may hold the workspace lock; a lock held by someone else
+// refuses the write.
+func (h *TFEHandler) CreateStateVersion(w http.ResponseWriter, r *http.Request) {
+	workspace, access, ok := h.workspace(w, r, chi.URLParam(r, "workspaceId"))
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, access, models.PermissionApply) {
+		return
+	}
+
+	var req struct {
+		Data struct {
+			Attributes struct {
+				Serial  int    `json:"serial"`
+				MD5     string `json:"md5"`
+				Lineage string `json:"lineage"`
+				State   string `json:"state"`
+				Force   bool   `json:"force"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeTFEError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	attrs := req.Data.Attributes
+
+	// Newer terraform first offers to upload state separately and sends it
+	// inline when told the state attribute is required, in these words.
+	if attrs.State == "" {
+		writeTFEError(w, http.StatusUnprocessableEntity, "param is missing or the value is empty: state")
+		return
+	}
+	body, err := base64.StdEncoding.DecodeString(attrs.State)
+	if err != nil || len(body) == 0 {
+		writeTFEError(w, http.StatusUnprocessableEntity, "state must be a base64-encoded state file")
+		return
+	}
+	if attrs.MD5 != "" && !strings.EqualFold(attrs.MD5, fmt.Sprintf("%x", md5.Sum(body))) {
+		writeTFEError(w, http.StatusUnprocessableEntity, "md5 checksum does not match the state")
+		return
+	}
+
+	user := middleware.GetUser(r)
+	if workspace.Locked && (workspace.LockedBy == nil || *workspace.LockedBy != user.ID) {
+		writeTFEError(w, http.StatusConflict, "workspace is locked by someone else")
+		return
+	}
+
+	state, err := h.states.Save(body, services.StateWrite{
+		WorkspaceID: workspace.ID,
+		CreatedBy:   user.ID,
+		Force:       attrs.Force,
+	})
+	if err != nil {
+		writeTFEError(w, tfeStateErrorStatus(err), err.Error())
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: workspace.Project.OrganizationID,
+		Action:         "state.upload",
+		ResourceType:   "workspace",
+		ResourceID:     workspace.ID,
+		ResourceName:   workspace.Name,
+	}, map[string]interface{}{
+		"version_id": state.ID,
+		"serial":     state.Serial,
+		"lineage":    state.Lineage,
+		"force":      attrs.Force,
+	})
+
+	writeTFEResource(w, http.StatusCreated, h.stateVersionResource(r, access, state))
+}
+
+// tfeStateErrorStatus maps errors of StateService.Save like writeStateError.
+func tfeStateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidState):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrStateConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// stateVersionColumns are the columns state version resources are built
+// from, leaving out the state itself.
+var stateVersionColumns = []string{"id", "workspace_id", "run_id", "serial", "lineage", "state_size", "state_md5", "outputs", "resource_count", "created_at"}
+
+// stateVersionResource describes a state version. Only callers who may
+// reveal outputs get the download URL, as the raw state holds sensitive
+// values in clear.
+func (h *TFEHandler) stateVersionResource(r *http.Request, access *middleware.Access, state *models.StateVersion) tfeResource {
+	relationships := map[string]interface{}{
+		"workspace": tfeRelationship("workspaces", state.WorkspaceID),
+	}
+	if state.RunID != nil {
+		relationships["run"] = tfeRelationship("runs", *state.RunID)
+	}
+	attrs := map[string]interface{}{
+		"serial":              state.Serial,
+		"lineage":             state.Lineage,
+		"md5":                 state.StateMD5,
+		"size":                state.StateSize,
+		"status":              "finalized",
+		"resources-processed": true,
+		"created-at":          state.CreatedAt,
+	}
+	if access.Can(models.PermissionRevealOutputs) {
+		attrs["hosted-state-download-url"] = h.signedURL(r, "/api/v2/state-versions/"+state.ID+"/download", stateURLTTL)
+	}
+	return tfeResource{
+		ID:            state.ID,
+		Type:          "state-versions",
+		Attributes:    attrs,
+		Relationships: relationships,
+	}
+}
+
+// GetCurrentStateVersionOutputs lists the outputs of the current state, which
+// terraform reads for terraform output and terraform_remote_state. The values
+// of sensitive outputs are left out; terraform reads them one by one.
+func (h *TFEHandler) GetCurrentStateVersionOutputs(w http.ResponseWriter, r *http.Request) {
+	workspace, access, ok := h.workspace(w, r, chi.URLParam(r, "workspaceId"))
+	if !ok || !h.authorize(w, r, access, models.PermissionReadState) {
+		return
+	}
+	state, err := services.CurrentState(h.db, workspace.ID, "id", "outputs")
+	if err != nil {
+		writeTFEError(w, http.StatusNotFound, "workspace has no state")
+		return
+	}
+	outputs, err := services.ParseOutputs(state.Outputs)
+	if err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to read outputs")
+		return
+	}
+
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resources := make([]tfeResource, 0, len(names))
+	for _, name := range names {
+		output := outputs[name]
+		if output.Sensitive {
+			output.Value = nil
+		}
+		resources = append(resources, stateOutputResource(state.ID, name, output))
+	}
+	writeTFEList(w, resources)
+}
+
+// GetStateVersionOutput returns one output with its value. As with
+// RevealOutput, viewers may not read sensitive outputs and reads of them are
+// audited.
+func (h *TFEHandler) GetStateVersionOutput(w http.ResponseWriter, r *http.Request) {
+	versionID, name, _ := strings.Cut(chi.URLParam(r, "outputId"), ".")
+
+	var state models.StateVersion
+	if err := h.db.Select("id", "workspace_id", "outputs").First(&state, "id = ?", versionID).Error; err != nil {
+		writeTFEError(w, http.StatusNotFound, "output not found")
+		return
+	}
+	workspace, access, ok := h.workspace(w, r, state.WorkspaceID)
+	if !ok || !h.authorize(w, r, access, models.PermissionReadState) {
+		return
+	}
+	outputs, err := services.ParseOutputs(state.Outputs)
+	if err != nil {
+		writeTFEError(w, http.StatusInternalServerError, "failed to read outputs")
+		return
+	}
+	output, ok := outputs[name]
+	if !ok {
+		writeTFEError(w, http.StatusNotFound, "output not found")
+		return
+	}
+
+	if output.Sensitive {
+		if !h.authorize(w, r, access, models.PermissionRevealOutputs) {
+			return
+		}
+		recordAudit(h.db, r, models.AuditLog{
+			OrganizationID: workspace.Project.OrganizationID,
+			Action:         "state.output.reveal",
+			ResourceType:   "workspace",
+			ResourceID:     workspace.ID,
+			ResourceName:   workspace.Name,
+		}, map[string]interface{}{
+			"output":           name,
+			"state_version_id": state.ID,
+		})
+	}
+
+	writeTFEResource(w, http.StatusOK, stateOutputResource(state.ID, name, output))
+}
+
+// stateOutputResource builds a state version output. Its ID is the state
+// version's ID and the output name joined by a dot.
+func stateOutputResource(versionID, name string, output services.StateOutput) tfeResource {
+	simpleType := "string"
+	switch t := output.Type.(type) {
+	case string:
+		simpleType = t
+	case []interface{}:
+		if len(t) > 0 {
+			if kind, ok := t[0].(string); ok {
+				simpleType = kind
+			}
+		}
+	}
+	return tfeResource{
+		ID:   versionID + "." + name,
+		Type: "state-version-outputs",
+		Attributes: map[string]interface{}{
+			"name":          name,
+			"sensitive":     output.Sensitive,
+			"type":          simpleType,
+			"value":         output.Value,
+			"detailed-type": output.Type,
+		},
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/middleware"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+	"gorm.io/gorm"
+)
+
+// TokenHandler manages API tokens: personal tokens of the current user, and
+// organization and team tokens of an organization.
+type TokenHandler struct {
+	db *gorm.DB
+}
+
+func NewTokenHandler(db *gorm.DB) *TokenHandler {
+	return &TokenHandler{db: db}
+}
+
+type createTokenRequest struct {
+	Description string     `json:"description"`
+	TeamID      *string    `json:"team_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// createdToken is the only response that carries the token itself.
+type createdToken struct {
+	models.APIToken
+	Token string `json:"token"`
+}
+
+func (h *TokenHandler) ListUserTokens(w http.ResponseWriter, r *http.Request) {
+	if !h.canManageTokens(w, r) {
+		return
+	}
+
+	var tokens []models.APIToken
+	h.db.Where("user_id = ? AND kind = ?", middleware.GetUser(r).ID, models.APITokenUser).Order("created_at DESC").Find(&tokens)
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+func (h *TokenHandler) CreateUserToken(w http.ResponseWriter, r *http.Request) {
+	if !h.canManageTokens(w, r) {
+		return
+	}
+
+	req, ok := decodeTokenRequest(w, r)
+	if !ok {
+		return
+	}
+
+	created, ok := h.create(w, models.APIToken{
+		UserID:      middleware.GetUser(r).ID,
+		Kind:        models.APITokenUser,
+		Description: req.Description,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *TokenHandler) DeleteUserToken(w http.ResponseWriter, r *http.Request) {
+	if !h.canManageTokens(w, r) {
+		return
+	}
+
+	result := h.db.Where("id = ? AND user_id = ? AND kind = ?", chi.URLParam(r, "tokenId"), middleware.GetUser(r).ID, models.APITokenUser).Delete(&models.APIToken{})
+	if result.Error != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke token"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Token not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Token revoked"})
+}
+
+// ListOrgTokens lists the organization's token and its teams' tokens.
+func (h *TokenHandler) ListOrgTokens(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+
+	if !h.canManageTokens(w, r) {
+		return
+	}
+	if !hasOrgRole(h.db, r, orgID, models.OrgRoleOwner, models.OrgRoleAdmin) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+		return
+	}
+
+	var tokens []models.APIToken
+	h.db.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&tokens)
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// CreateOrgToken creates an organization token, or a team token when a
+// team_id is given.
+func (h *TokenHandler) CreateOrgToken(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+
+	if !h.canManageTokens(w, r) {
+		return
+	}
+	if !hasOrgRole(h.db, r, orgID, models.OrgRoleOwner, models.OrgRoleAdmin) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+		return
+	}
+
+	req, ok := decodeTokenRequest(w, r)
+	if !ok {
+		return
+	}
+
+	token := models.APIToken{
+		UserID:         middleware.GetUser(r).ID,
+		Kind:           models.APITokenOrganization,
+		OrganizationID: &orgID,
+		Description:    req.Description,
+		ExpiresAt:      req.ExpiresAt,
+	}
+	resourceType, resourceID := "organization", orgID
+	if req.TeamID != nil {
+		var team models.Team
+		if err := h.db.Where("id = ? AND organization_id = ?", *req.TeamID, orgID).First(&team).Error; err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "Team not found"})
+			return
+		}
+		token.Kind = models.APITokenTeam
+		token.TeamID = &team.ID
+		resourceType, resourceID = "team", team.ID
+	}
+
+	created, ok := h.create(w, token)
+	if !ok {
+		return
+	}
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: orgID,
+		Action:         "token.create",
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+	}, map[string]interface{}{"token_id": created.ID, "kind": token.Kind, "description": token.Description})
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *TokenHandler) DeleteOrgToken(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgId")
+	tokenID := chi.URLParam(r, "tokenId")
+
+	if !h.canManageTokens(w, r) {
+		return
+	}
+	if !hasOrgRole(h.db, r, orgID, models.OrgRoleOwner, models.OrgRoleAdmin) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+		return
+	}
+
+	var token models.APIToken
+	if err := h.db.Where("id = ? AND organization_id = ?", tokenID, orgID).First(&token).Error; err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Token not found"})
+		return
+	}
+	if err := h.db.Delete(&token).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke token"})
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditLog{
+		OrganizationID: orgID,
+		Action:         "token.revoke",
+		ResourceType:   "api_token",
+		ResourceID:     token.ID,
+	}, map[string]interface{}{"kind": token.Kind, "description": token.Description})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Token revoked"})
+}
+
+// canManageTokens keeps organization and team tokens, which are scoped to
+// one organization, from minting tokens that reach further.
+func (h *TokenHandler) canManageTokens(w http.ResponseWriter, r *http.Request) bool {
+	if token := middleware.GetAPIToken(r); token != nil && token.Kind != models.APITokenUser {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Organization and team tokens cannot manage API tokens"})
+		return false
+	}
+	return true
+}
+
+func decodeTokenRequest(w http.ResponseWriter, r *http.Request) (*createTokenRequest, bool) {
+	var req createTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return nil, false
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expires_at must be in the future"})
+		return nil, false
+	}
+	return &req, true
+}
+
+// create stores a new token. The token itself is only part of this
+// response; it cannot be retrieved again.
+func (h *TokenHandler) create(w http.ResponseWriter, token models.APIToken) (*createdToken, bool) {
+	secret, hash, err := services.NewAPIToken()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+		return nil, false
+	}
+	token.TokenHash = hash
+
+	if err := h.db.Create(&token).Error; err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create token"})
+		return nil, false
+	}
+	return &createdToken{APIToken: token, Token: secret}, true
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/models"
+)
+
+// RequireAdmin restricts routes to site administrators. It must run after
+// AuthMiddleware.
+func RequireAdmin(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUser(r)
+			if user == nil || !IsAdmin(cfg, user) {
+				http.Error(w, `{"error":"Administrator access required"}`, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsAdmin reports whether a user is a site administrator, i.e. their email is
+// listed in ADMIN_EMAILS.
+func IsAdmin(cfg *config.Config, user *models.User) bool {
+	for _, email := range strings.Split(cfg.AdminEmails, ",") {
+		email = strings.TrimSpace(email)
+		if email != "" && strings.EqualFold(email, user.Email) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,82 +1,211 @@
-package middleware
-
-import (
-	"context"
-	"net/http"
-	"strings"
-
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/terraconsole/api/internal/config"
-	"github.com/terraconsole/api/internal/models"
-	"gorm.io/gorm"
-)
-
-type contextKey string
-
-const UserContextKey contextKey = "user"
-
-func AuthMiddleware(cfg *config.Config, db *gorm.DB) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, `{"error":"Authorization header required"}`, http.StatusUnauthorized)
-				return
-			}
-
-			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				http.Error(w, `{"error":"Invalid authorization format"}`, http.StatusUnauthorized)
-				return
-			}
-
-			tokenString := parts[1]
-
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, jwt.ErrSignatureInvalid
-				}
-				return []byte(cfg.JWTSecret), nil
-			})
-
-			if err != nil || !token.Valid {
-				http.Error(w, `{"error":"Invalid or expired token"}`, http.StatusUnauthorized)
-				return
-			}
-
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				http.Error(w, `{"error":"Invalid token claims"}`, http.StatusUnauthorized)
-				return
-			}
-
-			userID, ok := claims["sub"].(string)
-			if !ok {
-				http.Error(w, `{"error":"Invalid token subject"}`, http.StatusUnauthorized)
-				return
-			}
-
-			var user models.User
-			if err := db.First(&user, "id = ?", userID).Error; err != nil {
-				http.Error(w, `{"error":"User not found"}`, http.StatusUnauthorized)
-				return
-			}
-
-			if !user.IsActive {
-				http.Error(w, `{"error":"Account is disabled"}`, http.StatusForbidden)
-				return
-			}
-
-			ctx := context.WithValue(r.Context(), UserContextKey, &user)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
-	}
-}
-
-func GetUser(r *http.Request) *models.User {
-	user, ok := r.Context().Value(UserContextKey).(*models.User)
-	if !ok {
-		return nil
-	}
-	return user
-}
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/models"
+	"github.com/terraconsole/api/internal/services"
+	"gorm.io/gorm"
+)
+
+type contextKey string
+
+const (
+	UserContextKey         contextKey = "user"
+	RunWorkspaceContextKey contextKey = "run_workspace"
+	APITokenContextKey     contextKey = "api_token"
+	SessionContextKey      contextKey = "session"
+)
+
+// AuthMiddleware authenticates requests by a JWT or an API token, sent as a
+// bearer token or, for API tokens, as the basic auth password.
+func AuthMiddleware(cfg *config.Config, db *gorm.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" && isStreamRequest(r) {
+				// Browsers cannot set headers on WebSocket or EventSource
+				// requests, so streams may pass the token as a query parameter.
+				if token := r.URL.Query().Get("token"); token != "" {
+					authHeader = "Bearer " + token
+				}
+			}
+			if authHeader == "" {
+				http.Error(w, `{"error":"Authorization header required"}`, http.StatusUnauthorized)
+				return
+			}
+
+			var tokenString string
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+				tokenString = parts[1]
+			} else if _, password, ok := r.BasicAuth(); ok && services.IsAPIToken(password) {
+				tokenString = password
+			} else {
+				http.Error(w, `{"error":"Invalid authorization format"}`, http.StatusUnauthorized)
+				return
+			}
+
+			id, authErr := authenticate(cfg, db, tokenString, false)
+			if authErr != nil {
+				http.Error(w, `{"error":"`+authErr.message+`"}`, authErr.status)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(id.context(r.Context())))
+		})
+	}
+}
+
+// authError is why a request could not be authenticated, and the status to
+// answer it with.
+type authError struct {
+	status  int
+	message string
+}
+
+// identity is who a request was authenticated as.
+type identity struct {
+	user         *models.User
+	runWorkspace string
+	apiToken     *models.APIToken
+	sessionID    string
+}
+
+func (id *identity) context(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, UserContextKey, id.user)
+	if id.runWorkspace != "" {
+		ctx = context.WithValue(ctx, RunWorkspaceContextKey, id.runWorkspace)
+	}
+	if id.apiToken != nil {
+		ctx = context.WithValue(ctx, APITokenContextKey, id.apiToken)
+	}
+	if id.sessionID != "" {
+		ctx = context.WithValue(ctx, SessionContextKey, id.sessionID)
+	}
+	return ctx
+}
+
+// authenticate resolves an API token or JWT to the user it acts for. Run
+// tokens are only accepted when allowRunTokens is set.
+func authenticate(cfg *config.Config, db *gorm.DB, tokenString string, allowRunTokens bool) (*identity, *authError) {
+	id := &identity{}
+	var userID string
+	if services.IsAPIToken(tokenString) {
+		apiToken, err := services.FindAPIToken(db, tokenString, time.Now())
+		if errors.Is(err, services.ErrInvalidAPIToken) {
+			return nil, &authError{http.StatusUnauthorized, "Invalid or expired token"}
+		}
+		if err != nil {
+			return nil, &authError{http.StatusInternalServerError, "Failed to check token"}
+		}
+		id.apiToken = apiToken
+		userID = apiToken.UserID
+	} else {
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return []byte(cfg.JWTSecret), nil
+		})
+
+		if err != nil || !token.Valid {
+			return nil, &authError{http.StatusUnauthorized, "Invalid or expired token"}
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, &authError{http.StatusUnauthorized, "Invalid token claims"}
+		}
+
+		if claims["typ"] == services.RunTokenType {
+			id.runWorkspace, _ = claims["ws"].(string)
+			if !allowRunTokens || id.runWorkspace == "" {
+				return nil, &authError{http.StatusForbidden, "Run tokens can only read remote state"}
+			}
+		}
+
+		userID, ok = claims["sub"].(string)
+		if !ok {
+			return nil, &authError{http.StatusUnauthorized, "Invalid token subject"}
+		}
+
+		// Access tokens are only good while their session is.
+		if id.runWorkspace == "" {
+			id.sessionID, _ = claims["sid"].(string)
+			if id.sessionID == "" {
+				return nil, &authError{http.StatusUnauthorized, "Invalid or expired token"}
+			}
+			err := services.CheckSession(db, id.sessionID, userID, time.Now())
+			if errors.Is(err, services.ErrSessionRevoked) {
+				return nil, &authError{http.StatusUnauthorized, "Session has been revoked"}
+			}
+			if err != nil {
+				return nil, &authError{http.StatusInternalServerError, "Failed to check session"}
+			}
+		}
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, &authError{http.StatusUnauthorized, "User not found"}
+	}
+
+	// Organization and team tokens stop working once their creator is no
+	// longer an admin of the organization.
+	if id.apiToken != nil && id.apiToken.OrganizationID != nil {
+		var count int64
+		db.Model(&models.OrgMember{}).
+			Where("organization_id = ? AND user_id = ? AND role IN ?", *id.apiToken.OrganizationID, user.ID, []models.OrgRole{models.OrgRoleOwner, models.OrgRoleAdmin}).
+			Count(&count)
+		if count == 0 {
+			return nil, &authError{http.StatusUnauthorized, "Invalid or expired token"}
+		}
+	}
+
+	if !user.IsActive {
+		return nil, &authError{http.StatusForbidden, "Account is disabled"}
+	}
+
+	id.user = &user
+	return id, nil
+}
+
+func isStreamRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func GetUser(r *http.Request) *models.User {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		return nil
+	}
+	return user
+}
+
+// GetRunWorkspace returns the workspace of the run a request was made by, or
+// "" when a user made it.
+func GetRunWorkspace(r *http.Request) string {
+	wsID, _ := r.Context().Value(RunWorkspaceContextKey).(string)
+	return wsID
+}
+
+// GetSessionID returns the session a request's access token belongs to, or
+// "" when it was made with an API or run token.
+func GetSessionID(r *http.Request) string {
+	sessionID, _ := r.Context().Value(SessionContextKey).(string)
+	return sessionID
+}
+
+// GetAPIToken returns the API token a request was authenticated with, or nil
+// when it was made with a JWT.
+func GetAPIToken(r *http.Request) *models.APIToken {
+	token, _ := r.Context().Value(APITokenContextKey).(*models.APIToken)
+	return token
+}
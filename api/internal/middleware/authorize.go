@@ -0,0 +1,256 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+const AccessContextKey contextKey = "access"
+
+// ErrMFARequired is returned for a member of an organization that requires
+// MFA when they have not enrolled.
+var ErrMFARequired = errors.New("organization requires MFA")
+
+// Scope is what a request addresses: a resource and the organization,
+// project and workspace it belongs to.
+type Scope struct {
+	OrganizationID string
+	ProjectID      string
+	WorkspaceID    string
+	ResourceType   string
+	ResourceID     string
+}
+
+// Access is the caller's standing in the organization a request addresses:
+// their role, and what their teams were granted on the project or workspace.
+// Member is nil when the caller is not a member.
+type Access struct {
+	Scope
+	Member *models.OrgMember
+	// granted holds the permissions of team grants that apply to the scope.
+	granted map[models.Permission]bool
+	// teamToken marks requests made with a team token, which have their
+	// team's grants and not the role of the token's creator.
+	teamToken bool
+}
+
+// Can reports whether the caller has a permission within the scope.
+func (a *Access) Can(perm models.Permission) bool {
+	if a == nil || a.Member == nil {
+		return false
+	}
+	if a.granted[perm] {
+		return true
+	}
+	return !a.teamToken && a.Member.Role.Can(perm)
+}
+
+// Authorize resolves the organization that owns the resource named by the
+// request's route parameters, the run, workspace, project or organization ID,
+// and lets the request through if the caller's role there or their teams'
+// grants on the project or workspace give perm.
+// Denials are audited. It must run after AuthMiddleware or
+// StateAuthMiddleware; requests made with a run token are always denied.
+func Authorize(db *gorm.DB, perm models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope, ok := requestScope(db, r)
+			if !ok {
+				http.Error(w, `{"error":"Not found"}`, http.StatusNotFound)
+				return
+			}
+
+			access, err := ResolveAccess(db, r, scope)
+			if errors.Is(err, ErrMFARequired) {
+				http.Error(w, `{"error":"This organization requires MFA. Enable it under Settings to continue."}`, http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				http.Error(w, `{"error":"Failed to check permissions"}`, http.StatusInternalServerError)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), AccessContextKey, access))
+			if !Require(db, w, r, perm) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Require checks a permission against the access Authorize resolved for the
+// request, for actions whose permission depends on more than the route.
+// A denial is audited and answered with 403.
+func Require(db *gorm.DB, w http.ResponseWriter, r *http.Request, perm models.Permission) bool {
+	access := GetAccess(r)
+	if GetRunWorkspace(r) == "" && access.Can(perm) {
+		return true
+	}
+	AuditDenied(db, r, access, perm)
+	http.Error(w, `{"error":"Access denied"}`, http.StatusForbidden)
+	return false
+}
+
+// GetAccess returns the access Authorize resolved for a request.
+func GetAccess(r *http.Request) *Access {
+	access, _ := r.Context().Value(AccessContextKey).(*Access)
+	return access
+}
+
+// ResolveAccess looks up the caller's membership of the scope's
+// organization and the team grants on the scope's project or workspace: the
+// grants of the caller's teams, or of the team a team token belongs to.
+func ResolveAccess(db *gorm.DB, r *http.Request, scope Scope) (*Access, error) {
+	access := &Access{Scope: scope}
+	member, err := FindMember(db, r, scope.OrganizationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return access, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	access.Member = member
+
+	token := GetAPIToken(r)
+	access.teamToken = token != nil && token.TeamID != nil
+	if scope.ProjectID == "" {
+		return access, nil
+	}
+
+	query := db.Model(&models.TeamAccess{})
+	if access.teamToken {
+		query = query.Where("team_id = ?", *token.TeamID)
+	} else {
+		query = query.Where("team_id IN (?)", db.Model(&models.TeamMember{}).Select("team_id").Where("user_id = ?", member.UserID))
+	}
+	if scope.WorkspaceID != "" {
+		query = query.Where("(project_id = ? OR workspace_id = ?)", scope.ProjectID, scope.WorkspaceID)
+	} else {
+		query = query.Where("project_id = ?", scope.ProjectID)
+	}
+	var grants []models.TeamAccess
+	if err := query.Find(&grants).Error; err != nil {
+		return nil, err
+	}
+
+	access.granted = make(map[models.Permission]bool)
+	for i := range grants {
+		for _, perm := range grants[i].Granted() {
+			access.granted[perm] = true
+		}
+	}
+	return access, nil
+}
+
+// FindMember looks up the caller's membership of an organization. A request
+// made with an organization or team token only reaches the token's
+// organization. Members without MFA get ErrMFARequired from an organization
+// that requires it.
+func FindMember(db *gorm.DB, r *http.Request, orgID string) (*models.OrgMember, error) {
+	if token := GetAPIToken(r); token != nil && token.OrganizationID != nil && *token.OrganizationID != orgID {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	user := GetUser(r)
+	var member models.OrgMember
+	if err := db.Where("organization_id = ? AND user_id = ?", orgID, user.ID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	if !user.MFAEnabled {
+		var required int64
+		if err := db.Model(&models.Organization{}).Where("id = ? AND require_mfa", orgID).Count(&required).Error; err != nil {
+			return nil, err
+		}
+		if required > 0 {
+			return nil, ErrMFARequired
+		}
+	}
+	return &member, nil
+}
+
+// AuditDenied records in the organization's audit log that the caller was
+// refused a permission, whether or not they are a member.
+func AuditDenied(db *gorm.DB, r *http.Request, access *Access, perm models.Permission) {
+	user := GetUser(r)
+	if access == nil || user == nil {
+		return
+	}
+
+	details := map[string]interface{}{
+		"permission": perm,
+		"method":     r.Method,
+		"path":       r.URL.Path,
+		"role":       nil,
+	}
+	if access.Member != nil {
+		details["role"] = access.Member.Role
+	}
+	if token := GetAPIToken(r); token != nil && token.TeamID != nil {
+		details["team_id"] = *token.TeamID
+	}
+	if wsID := GetRunWorkspace(r); wsID != "" {
+		details["run_workspace"] = wsID
+	}
+	data, _ := json.Marshal(details)
+
+	entry := models.AuditLog{
+		OrganizationID: access.OrganizationID,
+		UserID:         user.ID,
+		Action:         "access.denied",
+		ResourceType:   access.ResourceType,
+		ResourceID:     access.ResourceID,
+		Details:        string(data),
+		IPAddress:      r.RemoteAddr,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to write audit log %s: %v", entry.Action, err)
+	}
+}
+
+// requestScope resolves the most specific resource the request's route
+// parameters name. Malformed IDs are not found like unknown ones.
+func requestScope(db *gorm.DB, r *http.Request) (Scope, bool) {
+	var scope, owner Scope
+	var err error
+	switch {
+	case chi.URLParam(r, "runId") != "":
+		scope.ResourceType, scope.ResourceID = "run", chi.URLParam(r, "runId")
+		err = db.Table("runs").
+			Select("projects.organization_id, workspaces.project_id, runs.workspace_id").
+			Joins("JOIN workspaces ON workspaces.id = runs.workspace_id AND workspaces.deleted_at IS NULL").
+			Joins("JOIN projects ON projects.id = workspaces.project_id AND projects.deleted_at IS NULL").
+			Where("runs.id = ?", scope.ResourceID).
+			Limit(1).Scan(&owner).Error
+	case chi.URLParam(r, "workspaceId") != "":
+		scope.ResourceType, scope.ResourceID = "workspace", chi.URLParam(r, "workspaceId")
+		err = db.Table("workspaces").
+			Select("projects.organization_id, workspaces.project_id, workspaces.id AS workspace_id").
+			Joins("JOIN projects ON projects.id = workspaces.project_id AND projects.deleted_at IS NULL").
+			Where("workspaces.id = ? AND workspaces.deleted_at IS NULL", scope.ResourceID).
+			Limit(1).Scan(&owner).Error
+	case chi.URLParam(r, "projectId") != "":
+		scope.ResourceType, scope.ResourceID = "project", chi.URLParam(r, "projectId")
+		err = db.Table("projects").
+			Select("organization_id, id AS project_id").
+			Where("id = ? AND deleted_at IS NULL", scope.ResourceID).
+			Limit(1).Scan(&owner).Error
+	case chi.URLParam(r, "orgId") != "":
+		scope.ResourceType, scope.ResourceID = "organization", chi.URLParam(r, "orgId")
+		err = db.Table("organizations").
+			Select("id AS organization_id").
+			Where("id = ? AND deleted_at IS NULL", scope.ResourceID).
+			Limit(1).Scan(&owner).Error
+	}
+	if err != nil || owner.OrganizationID == "" {
+		return scope, false
+	}
+	scope.OrganizationID, scope.ProjectID, scope.WorkspaceID = owner.OrganizationID, owner.ProjectID, owner.WorkspaceID
+	return scope, true
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/terraconsole/api/internal/config"
+	"gorm.io/gorm"
+)
+
+// stateRealm is the basic auth realm of the state backend routes.
+const stateRealm = `Basic realm="TerraConsole state"`
+
+// StateAuthMiddleware authenticates terraform's http backend, which sends
+// its credentials with basic auth: the password is an API token, a run
+// token or a login token, and the username is ignored. Bearer tokens work
+// too. Unlike AuthMiddleware it answers with bare 401 and 403 statuses,
+// which terraform reports as missing and invalid credentials.
+func StateAuthMiddleware(cfg *config.Config, db *gorm.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var tokenString string
+			if _, password, ok := r.BasicAuth(); ok {
+				tokenString = password
+			} else if parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2); len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+				tokenString = parts[1]
+			}
+			if tokenString == "" {
+				stateAuthError(w, http.StatusUnauthorized)
+				return
+			}
+
+			id, authErr := authenticate(cfg, db, tokenString, true)
+			if authErr != nil {
+				stateAuthError(w, authErr.status)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(id.context(r.Context())))
+		})
+	}
+}
+
+func stateAuthError(w http.ResponseWriter, status int) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", stateRealm)
+	}
+	http.Error(w, http.StatusText(status), status)
+}
@@ -1,42 +1,194 @@
-package models
-
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
-type Organization struct {
-	ID          string         `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	Name        string         `json:"name" gorm:"uniqueIndex;not null"`
-	DisplayName string         `json:"display_name"`
-	Email       string         `json:"email"`
-	Description string         `json:"description"`
-	AvatarURL   string         `json:"avatar_url"`
-	OwnerID     string         `json:"owner_id" gorm:"type:uuid;not null"`
-	Owner       User           `json:"-" gorm:"foreignKey:OwnerID"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
-	Members     []OrgMember    `json:"members,omitempty" gorm:"foreignKey:OrganizationID"`
-	Projects    []Project      `json:"projects,omitempty" gorm:"foreignKey:OrganizationID"`
-}
-
-type OrgRole string
-
-const (
-	OrgRoleOwner  OrgRole = "owner"
-	OrgRoleAdmin  OrgRole = "admin"
-	OrgRoleMember OrgRole = "member"
-	OrgRoleViewer OrgRole = "viewer"
-)
-
-type OrgMember struct {
-	ID             string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	OrganizationID string    `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex:idx_org_user"`
-	UserID         string    `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_org_user"`
-	User           User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Role           OrgRole   `json:"role" gorm:"type:varchar(20);not null;default:'member'"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-}
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+type Organization struct {
+	ID                     string `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Name                   string `json:"name" gorm:"uniqueIndex;not null"`
+	DisplayName            string `json:"display_name"`
+	Email                  string `json:"email"`
+	Description            string `json:"description"`
+	AvatarURL              string `json:"avatar_url"`
+	MaxConcurrentRuns      int    `json:"max_concurrent_runs" gorm:"default:0"`
+	StateRetentionVersions int    `json:"state_retention_versions" gorm:"default:0"`
+	StateRetentionDays     int    `json:"state_retention_days" gorm:"default:0"`
+	// RequireMFA keeps members without MFA out of the organization until
+	// they enroll.
+	RequireMFA bool           `json:"require_mfa" gorm:"default:false"`
+	OwnerID    string         `json:"owner_id" gorm:"type:uuid;not null"`
+	Owner      User           `json:"-" gorm:"foreignKey:OwnerID"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+	Members    []OrgMember    `json:"members,omitempty" gorm:"foreignKey:OrganizationID"`
+	Projects   []Project      `json:"projects,omitempty" gorm:"foreignKey:OrganizationID"`
+}
+
+type OrgRole string
+
+const (
+	OrgRoleOwner  OrgRole = "owner"
+	OrgRoleAdmin  OrgRole = "admin"
+	OrgRoleMember OrgRole = "member"
+	OrgRoleViewer OrgRole = "viewer"
+)
+
+// Permission is an action on the projects, workspaces, runs and state of an
+// organization that roles grant.
+type Permission string
+
+const (
+	// PermissionRead covers projects, workspaces, variables, runs and their
+	// plans and logs.
+	PermissionRead Permission = "read"
+	// PermissionReadState covers state versions, outputs and resources.
+	PermissionReadState Permission = "read-state"
+	// PermissionRevealOutputs covers the values of sensitive outputs.
+	PermissionRevealOutputs Permission = "reveal-outputs"
+	// PermissionQueueRun covers queueing plans and cancelling and discarding
+	// runs.
+	PermissionQueueRun Permission = "queue-run"
+	// PermissionLock covers locking workspaces and releasing one's own lock.
+	PermissionLock Permission = "lock"
+	// PermissionApply covers approving runs, auto-apply and writing state.
+	PermissionApply Permission = "apply"
+	// PermissionWriteVariables covers creating, changing and deleting
+	// workspace variables.
+	PermissionWriteVariables Permission = "write-variables"
+	// PermissionManageWorkspaces covers creating, configuring and deleting
+	// projects and workspaces, rolling back state and releasing other
+	// users' locks.
+	PermissionManageWorkspaces Permission = "manage-workspaces"
+)
+
+// Permissions lists every permission, in the order they are documented.
+var Permissions = []Permission{
+	PermissionRead,
+	PermissionReadState,
+	PermissionRevealOutputs,
+	PermissionQueueRun,
+	PermissionLock,
+	PermissionApply,
+	PermissionWriteVariables,
+	PermissionManageWorkspaces,
+}
+
+// ValidPermission reports whether perm is a known permission.
+func ValidPermission(perm Permission) bool {
+	for _, p := range Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// rolePermissions is what the member and viewer roles may do. Owners and
+// admins may do everything.
+var rolePermissions = map[OrgRole][]Permission{
+	OrgRoleMember: {PermissionRead, PermissionReadState, PermissionRevealOutputs, PermissionQueueRun, PermissionLock},
+	OrgRoleViewer: {PermissionRead, PermissionReadState},
+}
+
+// Can reports whether the role grants a permission.
+func (role OrgRole) Can(perm Permission) bool {
+	if role == OrgRoleOwner || role == OrgRoleAdmin {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+type OrgMember struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string    `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex:idx_org_user"`
+	UserID         string    `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_org_user"`
+	User           User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Role           OrgRole   `json:"role" gorm:"type:varchar(20);not null;default:'member'"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Team groups members of an organization to grant them access to projects
+// and workspaces beyond their role, or to issue them a shared API token.
+type Team struct {
+	ID             string       `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string       `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex:idx_org_team"`
+	Name           string       `json:"name" gorm:"not null;uniqueIndex:idx_org_team"`
+	Description    string       `json:"description"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	Members        []TeamMember `json:"members,omitempty" gorm:"foreignKey:TeamID"`
+	Access         []TeamAccess `json:"access,omitempty" gorm:"foreignKey:TeamID"`
+}
+
+// TeamMember puts a member of the organization in a team.
+type TeamMember struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TeamID    string    `json:"team_id" gorm:"type:uuid;not null;uniqueIndex:idx_team_user"`
+	UserID    string    `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_team_user;index"`
+	User      User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TeamAccessLevel is a preset of permissions a team can be granted, or
+// custom for a hand-picked set.
+type TeamAccessLevel string
+
+const (
+	TeamAccessRead   TeamAccessLevel = "read"
+	TeamAccessPlan   TeamAccessLevel = "plan"
+	TeamAccessWrite  TeamAccessLevel = "write"
+	TeamAccessAdmin  TeamAccessLevel = "admin"
+	TeamAccessCustom TeamAccessLevel = "custom"
+)
+
+// teamAccessPermissions is what each preset access level grants.
+var teamAccessPermissions = map[TeamAccessLevel][]Permission{
+	TeamAccessRead:  {PermissionRead, PermissionReadState},
+	TeamAccessPlan:  {PermissionRead, PermissionReadState, PermissionQueueRun, PermissionLock},
+	TeamAccessWrite: {PermissionRead, PermissionReadState, PermissionRevealOutputs, PermissionQueueRun, PermissionLock, PermissionApply, PermissionWriteVariables},
+	TeamAccessAdmin: Permissions,
+}
+
+// ValidTeamAccessLevel reports whether level is a known access level.
+func ValidTeamAccessLevel(level TeamAccessLevel) bool {
+	_, ok := teamAccessPermissions[level]
+	return ok || level == TeamAccessCustom
+}
+
+// TeamAccess grants a team permissions on a project, and so on all of its
+// workspaces, or on a single workspace; exactly one of ProjectID and
+// WorkspaceID is set. Permissions lists the permissions of custom access.
+type TeamAccess struct {
+	ID          string          `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TeamID      string          `json:"team_id" gorm:"type:uuid;not null;uniqueIndex:idx_team_project;uniqueIndex:idx_team_workspace"`
+	ProjectID   *string         `json:"project_id" gorm:"type:uuid;uniqueIndex:idx_team_project"`
+	WorkspaceID *string         `json:"workspace_id" gorm:"type:uuid;uniqueIndex:idx_team_workspace"`
+	Access      TeamAccessLevel `json:"access" gorm:"type:varchar(20);not null"`
+	Permissions pq.StringArray  `json:"permissions" gorm:"type:text[]"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Granted returns the permissions the grant gives. Custom access always
+// includes read.
+func (a *TeamAccess) Granted() []Permission {
+	if a.Access != TeamAccessCustom {
+		return teamAccessPermissions[a.Access]
+	}
+	granted := []Permission{PermissionRead}
+	for _, p := range a.Permissions {
+		granted = append(granted, Permission(p))
+	}
+	return granted
+}
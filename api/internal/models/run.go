@@ -1,94 +1,174 @@
-package models
-
-import (
-	"time"
-)
-
-type RunStatus string
-
-const (
-	RunStatusPending       RunStatus = "pending"
-	RunStatusPlanning      RunStatus = "planning"
-	RunStatusPlanned       RunStatus = "planned"
-	RunStatusNeedsConfirm  RunStatus = "needs_confirmation"
-	RunStatusApplying      RunStatus = "applying"
-	RunStatusApplied       RunStatus = "applied"
-	RunStatusErrored       RunStatus = "errored"
-	RunStatusCancelled     RunStatus = "cancelled"
-	RunStatusDiscarded     RunStatus = "discarded"
-	RunStatusPlanOnly      RunStatus = "planned_and_finished"
-)
-
-type RunOperation string
-
-const (
-	RunOperationPlan    RunOperation = "plan"
-	RunOperationApply   RunOperation = "plan_and_apply"
-	RunOperationDestroy RunOperation = "destroy"
-	RunOperationRefresh RunOperation = "refresh"
-)
-
-type Run struct {
-	ID               string       `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	WorkspaceID      string       `json:"workspace_id" gorm:"type:uuid;not null;index"`
-	Workspace        Workspace    `json:"-" gorm:"foreignKey:WorkspaceID"`
-	Status           RunStatus    `json:"status" gorm:"type:varchar(30);not null;default:'pending'"`
-	Operation        RunOperation `json:"operation" gorm:"type:varchar(20);not null"`
-	Message          string       `json:"message"`
-	IsDestroy        bool         `json:"is_destroy" gorm:"default:false"`
-	AutoApply        bool         `json:"auto_apply" gorm:"default:false"`
-	TerraformVersion string       `json:"terraform_version"`
-	CreatedBy        string       `json:"created_by" gorm:"type:uuid;not null"`
-	Creator          User         `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
-	PlanLog          string       `json:"-" gorm:"type:text"`
-	PlanJSON         string       `json:"-" gorm:"type:text"`
-	ApplyLog         string       `json:"-" gorm:"type:text"`
-	ResourcesAdded   int          `json:"resources_added" gorm:"default:0"`
-	ResourcesChanged int          `json:"resources_changed" gorm:"default:0"`
-	ResourcesDeleted int          `json:"resources_deleted" gorm:"default:0"`
-	StartedAt        *time.Time   `json:"started_at"`
-	PlanCompletedAt  *time.Time   `json:"plan_completed_at"`
-	AppliedAt        *time.Time   `json:"applied_at"`
-	CompletedAt      *time.Time   `json:"completed_at"`
-	CreatedAt        time.Time    `json:"created_at"`
-	UpdatedAt        time.Time    `json:"updated_at"`
-}
-
-type StateVersion struct {
-	ID           string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	WorkspaceID  string    `json:"workspace_id" gorm:"type:uuid;not null;index"`
-	RunID        *string   `json:"run_id" gorm:"type:uuid"`
-	Serial       int       `json:"serial" gorm:"not null"`
-	Lineage      string    `json:"lineage"`
-	State        []byte    `json:"-" gorm:"type:bytea"`
-	StateHash    string    `json:"state_hash"`
-	Outputs      string    `json:"outputs" gorm:"type:text"`
-	ResourceCount int      `json:"resource_count" gorm:"default:0"`
-	CreatedAt    time.Time `json:"created_at"`
-	CreatedBy    string    `json:"created_by" gorm:"type:uuid"`
-}
-
-type AuditLog struct {
-	ID             string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	OrganizationID string    `json:"organization_id" gorm:"type:uuid;not null;index"`
-	UserID         string    `json:"user_id" gorm:"type:uuid;not null"`
-	User           User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Action         string    `json:"action" gorm:"not null"`
-	ResourceType   string    `json:"resource_type" gorm:"not null"`
-	ResourceID     string    `json:"resource_id"`
-	ResourceName   string    `json:"resource_name"`
-	Details        string    `json:"details" gorm:"type:text"`
-	IPAddress      string    `json:"ip_address"`
-	CreatedAt      time.Time `json:"created_at"`
-}
-
-type Notification struct {
-	ID        string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	UserID    string    `json:"user_id" gorm:"type:uuid;not null;index"`
-	Title     string    `json:"title" gorm:"not null"`
-	Message   string    `json:"message"`
-	Type      string    `json:"type" gorm:"type:varchar(30)"`
-	Read      bool      `json:"read" gorm:"default:false"`
-	LinkURL   string    `json:"link_url"`
-	CreatedAt time.Time `json:"created_at"`
-}
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type RunStatus string
+
+const (
+	RunStatusPending      RunStatus = "pending"
+	RunStatusPlanning     RunStatus = "planning"
+	RunStatusPlanned      RunStatus = "planned"
+	RunStatusNeedsConfirm RunStatus = "needs_confirmation"
+	RunStatusApplyQueued  RunStatus = "apply_queued"
+	RunStatusApplying     RunStatus = "applying"
+	RunStatusApplied      RunStatus = "applied"
+	RunStatusErrored      RunStatus = "errored"
+	RunStatusCancelled    RunStatus = "cancelled"
+	RunStatusDiscarded    RunStatus = "discarded"
+	RunStatusPlanOnly     RunStatus = "planned_and_finished"
+)
+
+type RunOperation string
+
+const (
+	RunOperationPlan    RunOperation = "plan"
+	RunOperationApply   RunOperation = "plan_and_apply"
+	RunOperationDestroy RunOperation = "destroy"
+	RunOperationRefresh RunOperation = "refresh"
+)
+
+type Run struct {
+	ID               string       `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	WorkspaceID      string       `json:"workspace_id" gorm:"type:uuid;not null;index"`
+	Workspace        Workspace    `json:"-" gorm:"foreignKey:WorkspaceID"`
+	Status           RunStatus    `json:"status" gorm:"type:varchar(30);not null;default:'pending'"`
+	Operation        RunOperation `json:"operation" gorm:"type:varchar(20);not null"`
+	Message          string       `json:"message"`
+	IsDestroy        bool         `json:"is_destroy" gorm:"default:false"`
+	AutoApply        bool         `json:"auto_apply" gorm:"default:false"`
+	TerraformVersion string       `json:"terraform_version"`
+	// ConfigurationVersionID is set for runs of configuration uploaded
+	// through the TFE API, e.g. by terraform's cloud backend, instead of the
+	// workspace's repository.
+	ConfigurationVersionID *string `json:"configuration_version_id" gorm:"type:uuid"`
+	CreatedBy              string  `json:"created_by" gorm:"type:uuid;not null"`
+	Creator                User    `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
+	PlanLog                string  `json:"-" gorm:"type:text"`
+	PlanJSON               string  `json:"-" gorm:"type:text"`
+	ApplyLog               string  `json:"-" gorm:"type:text"`
+	// Blob store keys of the plan log, apply log and plan JSON once they
+	// have been moved out of the columns above.
+	PlanLogBlob            string     `json:"-" gorm:"type:varchar(64)"`
+	ApplyLogBlob           string     `json:"-" gorm:"type:varchar(64)"`
+	PlanJSONBlob           string     `json:"-" gorm:"type:varchar(64)"`
+	PlanHash               string     `json:"-"`
+	PlanStateSerial        *int       `json:"plan_state_serial"`
+	PlanStateLineage       string     `json:"plan_state_lineage"`
+	ResourcesAdded         int        `json:"resources_added" gorm:"default:0"`
+	ResourcesChanged       int        `json:"resources_changed" gorm:"default:0"`
+	ResourcesDeleted       int        `json:"resources_deleted" gorm:"default:0"`
+	ResourcesReplaced      int        `json:"resources_replaced" gorm:"default:0"`
+	ResourcesImported      int        `json:"resources_imported" gorm:"default:0"`
+	StartedAt              *time.Time `json:"started_at"`
+	PlanCompletedAt        *time.Time `json:"plan_completed_at"`
+	AppliedAt              *time.Time `json:"applied_at"`
+	CompletedAt            *time.Time `json:"completed_at"`
+	QueuePosition          *int       `json:"queue_position"`
+	CancelRequestedAt      *time.Time `json:"cancel_requested_at"`
+	ForceCancelAvailableAt *time.Time `json:"force_cancel_available_at"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+type ConfigurationStatus string
+
+const (
+	ConfigurationPending  ConfigurationStatus = "pending"
+	ConfigurationUploaded ConfigurationStatus = "uploaded"
+	ConfigurationErrored  ConfigurationStatus = "errored"
+)
+
+// ConfigurationVersion is a configuration archive (a .tar.gz of the
+// configuration directory) uploaded for runs of a workspace.
+type ConfigurationVersion struct {
+	ID            string              `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	WorkspaceID   string              `json:"workspace_id" gorm:"type:uuid;not null;index"`
+	Status        ConfigurationStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	Speculative   bool                `json:"speculative" gorm:"default:false"`
+	AutoQueueRuns bool                `json:"auto_queue_runs" gorm:"default:false"`
+	// AutoApply is whether the run queued by the upload applies without
+	// confirmation: the workspace's setting, if the uploader may apply.
+	AutoApply   bool      `json:"auto_apply" gorm:"default:false"`
+	ArchiveBlob string    `json:"-" gorm:"type:varchar(64)"`
+	CreatedBy   string    `json:"created_by" gorm:"type:uuid;not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// StateEncoding describes how the state of a StateVersion is stored, in its
+// State column or in the blob store.
+type StateEncoding string
+
+const (
+	// StateEncodingNone is plaintext state, written before state was
+	// encrypted at rest.
+	StateEncodingNone StateEncoding = ""
+	// StateEncodingGzipAESGCM is gzip-compressed state encrypted with
+	// AES-256-GCM under the version's own data key.
+	StateEncodingGzipAESGCM StateEncoding = "gzip+aes256gcm"
+)
+
+type StateVersion struct {
+	ID            string        `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	WorkspaceID   string        `json:"workspace_id" gorm:"type:uuid;not null;index"`
+	RunID         *string       `json:"run_id" gorm:"type:uuid"`
+	Serial        int           `json:"serial" gorm:"not null"`
+	Lineage       string        `json:"lineage"`
+	State         []byte        `json:"-" gorm:"type:bytea"`
+	StateBlob     string        `json:"-" gorm:"type:varchar(64)"`
+	StateSize     int           `json:"state_size" gorm:"default:0"`
+	Encoding      StateEncoding `json:"-" gorm:"type:varchar(30);default:''"`
+	DataKey       string        `json:"-" gorm:"type:text"`
+	StateHash     string        `json:"state_hash"`
+	StateMD5      string        `json:"state_md5"`
+	Outputs       string        `json:"outputs" gorm:"type:text"`
+	ResourceCount int           `json:"resource_count" gorm:"default:0"`
+	CreatedAt     time.Time     `json:"created_at"`
+	CreatedBy     string        `json:"created_by" gorm:"type:uuid"`
+}
+
+// StateResource is a resource in the current state of a workspace. The
+// inventory is rebuilt on every state write so it can be searched without
+// decrypting state.
+type StateResource struct {
+	ID             string         `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	WorkspaceID    string         `json:"workspace_id" gorm:"type:uuid;not null;index"`
+	StateVersionID string         `json:"state_version_id" gorm:"type:uuid;not null"`
+	Address        string         `json:"address" gorm:"not null"`
+	Module         string         `json:"module" gorm:"index"`
+	Mode           string         `json:"mode" gorm:"type:varchar(20)"`
+	Type           string         `json:"type" gorm:"index"`
+	Name           string         `json:"name" gorm:"index"`
+	Provider       string         `json:"provider" gorm:"index"`
+	InstanceCount  int            `json:"instance_count"`
+	InstanceIDs    pq.StringArray `json:"instance_ids" gorm:"type:text[]"`
+}
+
+type AuditLog struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string    `json:"organization_id" gorm:"type:uuid;not null;index"`
+	UserID         string    `json:"user_id" gorm:"type:uuid;not null"`
+	User           User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Action         string    `json:"action" gorm:"not null"`
+	ResourceType   string    `json:"resource_type" gorm:"not null"`
+	ResourceID     string    `json:"resource_id"`
+	ResourceName   string    `json:"resource_name"`
+	Details        string    `json:"details" gorm:"type:text"`
+	IPAddress      string    `json:"ip_address"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type Notification struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string    `json:"user_id" gorm:"type:uuid;not null;index"`
+	Title     string    `json:"title" gorm:"not null"`
+	Message   string    `json:"message"`
+	Type      string    `json:"type" gorm:"type:varchar(30)"`
+	Read      bool      `json:"read" gorm:"default:false"`
+	LinkURL   string    `json:"link_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
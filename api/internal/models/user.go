@@ -1,34 +1,92 @@
-package models
-
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
-type User struct {
-	ID        string         `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	Email     string         `json:"email" gorm:"uniqueIndex;not null"`
-	Username  string         `json:"username" gorm:"uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"not null"`
-	FullName  string         `json:"full_name"`
-	AvatarURL string         `json:"avatar_url"`
-	MFAEnabled bool          `json:"mfa_enabled" gorm:"default:false"`
-	MFASecret  string        `json:"-"`
-	IsActive   bool          `json:"is_active" gorm:"default:true"`
-	LastLoginAt *time.Time   `json:"last_login_at"`
-	CreatedAt  time.Time     `json:"created_at"`
-	UpdatedAt  time.Time     `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
-}
-
-type APIToken struct {
-	ID          string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	UserID      string    `json:"user_id" gorm:"type:uuid;not null;index"`
-	User        User      `json:"-" gorm:"foreignKey:UserID"`
-	Description string    `json:"description"`
-	TokenHash   string    `json:"-" gorm:"uniqueIndex;not null"`
-	LastUsedAt  *time.Time `json:"last_used_at"`
-	ExpiresAt   *time.Time `json:"expires_at"`
-	CreatedAt   time.Time  `json:"created_at"`
-}
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type User struct {
+	ID         string `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Email      string `json:"email" gorm:"uniqueIndex;not null"`
+	Username   string `json:"username" gorm:"uniqueIndex;not null"`
+	Password   string `json:"-" gorm:"not null"`
+	FullName   string `json:"full_name"`
+	AvatarURL  string `json:"avatar_url"`
+	MFAEnabled bool   `json:"mfa_enabled" gorm:"default:false"`
+	MFASecret  string `json:"-"`
+	// MFAPendingSecret is the secret of an enrollment that has not been
+	// confirmed with a code yet.
+	MFAPendingSecret string         `json:"-"`
+	IsActive         bool           `json:"is_active" gorm:"default:true"`
+	LastLoginAt      *time.Time     `json:"last_login_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// MFARecoveryCode is a one-time code that stands in for a TOTP code when the
+// authenticator device is lost. Only its hash is stored.
+type MFARecoveryCode struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string     `json:"user_id" gorm:"type:uuid;not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Session is a signed-in browser or client. Access tokens carry the ID of
+// their session and stop working once it is revoked. The refresh token is
+// replaced on every use and only its hash is stored; the previous hash is
+// kept to notice a refresh token being used twice.
+type Session struct {
+	ID                string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID            string     `json:"user_id" gorm:"type:uuid;not null;index"`
+	RefreshTokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	PreviousTokenHash string     `json:"-" gorm:"index"`
+	RotatedAt         *time.Time `json:"-"`
+	UserAgent         string     `json:"user_agent"`
+	IPAddress         string     `json:"ip_address"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+}
+
+// APITokenKind says on whose behalf an API token acts. Organization and team
+// tokens act for the admin who created them, within that organization only.
+type APITokenKind string
+
+const (
+	APITokenUser         APITokenKind = "user"
+	APITokenTeam         APITokenKind = "team"
+	APITokenOrganization APITokenKind = "organization"
+)
+
+type APIToken struct {
+	ID             string       `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID         string       `json:"user_id" gorm:"type:uuid;not null;index"`
+	User           User         `json:"-" gorm:"foreignKey:UserID"`
+	Kind           APITokenKind `json:"kind" gorm:"type:varchar(20);not null;default:'user'"`
+	OrganizationID *string      `json:"organization_id" gorm:"type:uuid;index"`
+	TeamID         *string      `json:"team_id" gorm:"type:uuid;index"`
+	Description    string       `json:"description"`
+	TokenHash      string       `json:"-" gorm:"uniqueIndex;not null"`
+	LastUsedAt     *time.Time   `json:"last_used_at"`
+	ExpiresAt      *time.Time   `json:"expires_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// OAuthCode is an authorization code of the terraform login flow, exchanged
+// for an API token by the CLI. The code verifier must match CodeChallenge
+// (PKCE, S256).
+type OAuthCode struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CodeHash      string    `json:"-" gorm:"uniqueIndex;not null"`
+	UserID        string    `json:"user_id" gorm:"type:uuid;not null"`
+	ClientID      string    `json:"client_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	CodeChallenge string    `json:"-"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix starts every API token, which tells them apart from JWTs.
+const APITokenPrefix = "tc_"
+
+// apiTokenUseInterval throttles how often using a token updates its
+// LastUsedAt, so busy CI pipelines do not write on every request.
+const apiTokenUseInterval = time.Minute
+
+// ErrInvalidAPIToken is returned for unknown and expired API tokens.
+var ErrInvalidAPIToken = errors.New("invalid or expired API token")
+
+// NewAPIToken generates an API token. Only its hash is stored; the token
+// itself is shown once, when it is created.
+func NewAPIToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = APITokenPrefix + hex.EncodeToString(b)
+	return token, HashAPIToken(token), nil
+}
+
+// HashAPIToken returns the hash an API token is stored and looked up by.
+// Tokens are random, so a fast hash is enough.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIToken reports whether a bearer token or password is an API token.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// FindAPIToken looks up an unexpired API token and records that it was used.
+func FindAPIToken(db *gorm.DB, token string, now time.Time) (*models.APIToken, error) {
+	var apiToken models.APIToken
+	if err := db.First(&apiToken, "token_hash = ?", HashAPIToken(token)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIToken
+		}
+		return nil, err
+	}
+	if apiToken.ExpiresAt != nil && !now.Before(*apiToken.ExpiresAt) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) >= apiTokenUseInterval {
+		db.Model(&apiToken).UpdateColumn("last_used_at", now)
+		apiToken.LastUsedAt = &now
+	}
+	return &apiToken, nil
+}
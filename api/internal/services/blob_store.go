@@ -0,0 +1,141 @@
+package services
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/terraconsole/api/internal/config"
+)
+
+// ErrBlobNotFound is returned when a blob store holds no blob for a key.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps large, immutable content such as state and run logs out of
+// the database. Blobs are addressed by the SHA-256 of their content, so
+// storing the same content twice stores it once.
+type BlobStore interface {
+	Put(data []byte) (string, error)
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// NewBlobStore creates the blob store selected by BLOB_STORE.
+func NewBlobStore(cfg *config.Config) (BlobStore, error) {
+	switch cfg.BlobStore {
+	case "", "local":
+		dir := cfg.BlobDir
+		if dir == "" {
+			dir = filepath.Join(cfg.WorkingDir, "blobs")
+		}
+		return NewLocalBlobStore(dir), nil
+	case "s3":
+		return NewS3BlobStore(S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			Prefix:          cfg.S3Prefix,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", cfg.BlobStore)
+	}
+}
+
+// BlobKey returns the key a blob with the given content is stored under.
+func BlobKey(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// checkBlob guards against reading a corrupted or truncated blob.
+func checkBlob(key string, data []byte) error {
+	if BlobKey(data) != key {
+		return fmt.Errorf("blob %s is corrupt: content hash does not match", key)
+	}
+	return nil
+}
+
+// blobPath spreads blobs over subdirectories by the first bytes of their
+// key, e.g. 3f/3fa2...
+func blobPath(key string) (string, error) {
+	if len(key) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return key[:2] + "/" + key, nil
+}
+
+// LocalBlobStore stores blobs as files under a directory.
+type LocalBlobStore struct {
+	dir string
+}
+
+func NewLocalBlobStore(dir string) *LocalBlobStore {
+	return &LocalBlobStore{dir: dir}
+}
+
+func (s *LocalBlobStore) Put(data []byte) (string, error) {
+	key := BlobKey(data)
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return key, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return key, os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalBlobStore) Get(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, checkBlob(key, data)
+}
+
+func (s *LocalBlobStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalBlobStore) path(key string) (string, error) {
+	rel, err := blobPath(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(rel)), nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxConfigurationSize bounds an uploaded configuration archive.
+const MaxConfigurationSize = 100 << 20
+
+// CheckConfigurationArchive validates a configuration archive, a .tar.gz
+// like terraform's cloud backend uploads, without extracting it.
+func CheckConfigurationArchive(archive []byte) error {
+	return walkConfigurationArchive(archive, func(*tar.Header, string, io.Reader) error { return nil })
+}
+
+// ExtractConfiguration unpacks a configuration archive into dst. Entries
+// may not point outside dst, neither by path nor by symlink: no entry is
+// written through a symlink unpacked before it, and every symlink must
+// resolve to a path within dst once the archive is unpacked.
+func ExtractConfiguration(archive []byte, dst string) error {
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+	err := walkConfigurationArchive(archive, func(hdr *tar.Header, name string, body io.Reader) error {
+		if err := checkNoSymlinks(dst, name); err != nil {
+			return err
+		}
+		path := filepath.Join(dst, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			return os.MkdirAll(path, 0700)
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0700|0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, body); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			return os.Symlink(hdr.Linkname, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return checkSymlinkTargets(dst)
+}
+
+// checkNoSymlinks refuses an archive entry when its path, or any directory
+// on the way to it, is a symlink an earlier entry created. Symlink targets
+// are only checked lexically while unpacking, so chained links could
+// otherwise lead writes outside of dst.
+func checkNoSymlinks(dst, name string) error {
+	path := dst
+	for _, part := range strings.Split(name, string(filepath.Separator)) {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("entry %q in configuration archive is written through a symlink", name)
+		}
+	}
+	return nil
+}
+
+// checkSymlinkTargets refuses unpacked symlinks that resolve outside of dst.
+// Dangling symlinks lead nowhere and are left alone.
+func checkSymlinkTargets(dst string) error {
+	root, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+		target, err := filepath.EvalSymlinks(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid symlink %q in configuration archive: %w", path, err)
+		}
+		if rel, err := filepath.Rel(root, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			rel, _ = filepath.Rel(dst, path)
+			return fmt.Errorf("symlink %q in configuration archive points outside of it", rel)
+		}
+		return nil
+	})
+}
+
+func walkConfigurationArchive(archive []byte, visit func(hdr *tar.Header, name string, body io.Reader) error) error {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("configuration is not a .tar.gz archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid configuration archive: %w", err)
+		}
+
+		name, ok := archivePath(hdr.Name)
+		if !ok {
+			return fmt.Errorf("invalid path %q in configuration archive", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg:
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("symlink %q in configuration archive is absolute", hdr.Name)
+			}
+			if _, ok := archivePath(filepath.Join(filepath.Dir(name), hdr.Linkname)); !ok {
+				return fmt.Errorf("symlink %q in configuration archive points outside of it", hdr.Name)
+			}
+		default:
+			// Other entries, like PAX headers already merged by the tar
+			// reader, carry no configuration.
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		if err := visit(hdr, name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// archivePath cleans an archive entry name relative to the archive root, and
+// reports false for names that escape it.
+func archivePath(name string) (string, bool) {
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(name) {
+		return "", false
+	}
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if cleaned == "." {
+		return "", true
+	}
+	return cleaned, true
+}
@@ -1,75 +1,111 @@
-package services
-
-import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"encoding/base64"
-	"encoding/hex"
-	"errors"
-	"io"
-)
-
-type EncryptionService struct {
-	key []byte
-}
-
-func NewEncryptionService(keyHex string) *EncryptionService {
-	key, err := hex.DecodeString(keyHex)
-	if err != nil || len(key) != 32 {
-		// Fallback to using the key string directly padded/truncated to 32 bytes
-		key = make([]byte, 32)
-		copy(key, []byte(keyHex))
-	}
-	return &EncryptionService{key: key}
-}
-
-func (s *EncryptionService) Encrypt(plaintext string) (string, error) {
-	block, err := aes.NewCipher(s.key)
-	if err != nil {
-		return "", err
-	}
-
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonce := make([]byte, aesGCM.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
-	}
-
-	ciphertext := aesGCM.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
-}
-
-func (s *EncryptionService) Decrypt(encoded string) (string, error) {
-	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
-	if err != nil {
-		return "", err
-	}
-
-	block, err := aes.NewCipher(s.key)
-	if err != nil {
-		return "", err
-	}
-
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonceSize := aesGCM.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return "", errors.New("ciphertext too short")
-	}
-
-	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", err
-	}
-
-	return string(plaintext), nil
-}
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+type EncryptionService struct {
+	key []byte
+}
+
+func NewEncryptionService(keyHex string) *EncryptionService {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil || len(key) != 32 {
+		// Fallback to using the key string directly padded/truncated to 32 bytes
+		key = make([]byte, 32)
+		copy(key, []byte(keyHex))
+	}
+	return &EncryptionService{key: key}
+}
+
+func (s *EncryptionService) Encrypt(plaintext string) (string, error) {
+	ciphertext, err := Seal(s.key, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (s *EncryptionService) Decrypt(encoded string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := Open(s.key, ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// NewDataKey generates a random AES-256 key for encrypting a single object
+// and returns it along with a copy wrapped by the master key for storage.
+func (s *EncryptionService) NewDataKey() ([]byte, string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, "", err
+	}
+
+	wrapped, err := s.Encrypt(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		return nil, "", err
+	}
+	return key, wrapped, nil
+}
+
+// UnwrapDataKey decrypts a data key returned by NewDataKey.
+func (s *EncryptionService) UnwrapDataKey(wrapped string) ([]byte, error) {
+	encoded, err := s.Decrypt(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// Seal encrypts plaintext with AES-GCM under key. The nonce is prepended to
+// the ciphertext.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aesGCM.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts ciphertext produced by Seal.
+func Open(key, ciphertext []byte) ([]byte, error) {
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := aesGCM.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	return aesGCM.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets at enrollment.
+const RecoveryCodeCount = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes replaces a user's recovery codes with new ones and
+// returns them. They are formatted as xxxx-xxxx-xxxx-xxxx; only their hashes
+// are stored.
+func NewRecoveryCodes(db *gorm.DB, userID string) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	rows := make([]models.MFARecoveryCode, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+		rows[i] = models.MFARecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode spends one of a user's unused recovery codes, and reports
+// whether code was one. Codes are matched regardless of case, dashes and
+// spaces.
+func UseRecoveryCode(db *gorm.DB, userID, code string, now time.Time) (bool, error) {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if code == "" {
+		return false, nil
+	}
+	result := db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RemainingRecoveryCodes counts a user's unused recovery codes.
+func RemainingRecoveryCodes(db *gorm.DB, userID string) (int64, error) {
+	var count int64
+	err := db.Model(&models.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// hashRecoveryCode hashes a normalized recovery code. Codes carry 80 random
+// bits, so a fast hash is enough.
+func hashRecoveryCode(code string) string {
+	return HashAPIToken(code)
+}
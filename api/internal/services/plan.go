@@ -0,0 +1,170 @@
+package services
+
+import (
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// SensitiveValue replaces values terraform marked as sensitive.
+const SensitiveValue = "***SENSITIVE***"
+
+// PlanSummary counts managed resource changes the way the terraform CLI
+// reports them: a replacement is both an add and a destroy.
+type PlanSummary struct {
+	Added    int `json:"added"`
+	Changed  int `json:"changed"`
+	Deleted  int `json:"deleted"`
+	Replaced int `json:"replaced"`
+	Imported int `json:"imported"`
+}
+
+// ResourceChange is a single planned resource change with sensitive
+// attributes masked.
+type ResourceChange struct {
+	Address         string      `json:"address"`
+	PreviousAddress string      `json:"previous_address,omitempty"`
+	ModuleAddress   string      `json:"module_address,omitempty"`
+	Mode            string      `json:"mode"`
+	Type            string      `json:"type"`
+	Name            string      `json:"name"`
+	ProviderName    string      `json:"provider_name"`
+	Actions         []string    `json:"actions"`
+	Action          string      `json:"action"`
+	ImportingID     string      `json:"importing_id,omitempty"`
+	Before          interface{} `json:"before"`
+	After           interface{} `json:"after"`
+	AfterUnknown    interface{} `json:"after_unknown,omitempty"`
+}
+
+// SummarizePlan counts the managed resource changes in a plan.
+func SummarizePlan(plan *tfjson.Plan) PlanSummary {
+	var summary PlanSummary
+
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil || rc.Mode != tfjson.ManagedResourceMode {
+			continue
+		}
+
+		if rc.Change.Importing != nil {
+			summary.Imported++
+		}
+
+		actions := rc.Change.Actions
+		switch {
+		case actions.Replace():
+			summary.Replaced++
+			summary.Added++
+			summary.Deleted++
+		case actions.Create():
+			summary.Added++
+		case actions.Update():
+			summary.Changed++
+		case actions.Delete():
+			summary.Deleted++
+		}
+	}
+
+	return summary
+}
+
+// PlanResourceChanges lists every resource change in the plan that does
+// something, with before/after values masked by their sensitivity.
+func PlanResourceChanges(plan *tfjson.Plan) []ResourceChange {
+	changes := make([]ResourceChange, 0, len(plan.ResourceChanges))
+
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil {
+			continue
+		}
+		if rc.Change.Actions.NoOp() && rc.Change.Importing == nil && rc.PreviousAddress == "" {
+			continue
+		}
+
+		actions := make([]string, 0, len(rc.Change.Actions))
+		for _, a := range rc.Change.Actions {
+			actions = append(actions, string(a))
+		}
+
+		change := ResourceChange{
+			Address:         rc.Address,
+			PreviousAddress: rc.PreviousAddress,
+			ModuleAddress:   rc.ModuleAddress,
+			Mode:            string(rc.Mode),
+			Type:            rc.Type,
+			Name:            rc.Name,
+			ProviderName:    rc.ProviderName,
+			Actions:         actions,
+			Action:          changeAction(rc),
+			Before:          MaskSensitive(rc.Change.Before, rc.Change.BeforeSensitive),
+			After:           MaskSensitive(rc.Change.After, rc.Change.AfterSensitive),
+			AfterUnknown:    rc.Change.AfterUnknown,
+		}
+		if rc.Change.Importing != nil {
+			change.ImportingID = rc.Change.Importing.ID
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func changeAction(rc *tfjson.ResourceChange) string {
+	actions := rc.Change.Actions
+	switch {
+	case actions.Replace():
+		return "replace"
+	case actions.Create():
+		return "create"
+	case actions.Update():
+		return "update"
+	case actions.Delete():
+		return "delete"
+	case actions.Read():
+		return "read"
+	case rc.Change.Importing != nil:
+		return "import"
+	case rc.PreviousAddress != "":
+		return "move"
+	default:
+		return "no-op"
+	}
+}
+
+// MaskSensitive walks a value alongside terraform's sensitivity structure
+// (true for a sensitive leaf, nested maps/lists otherwise) and replaces
+// sensitive parts with SensitiveValue.
+func MaskSensitive(value, sensitive interface{}) interface{} {
+	switch s := sensitive.(type) {
+	case bool:
+		if s && value != nil {
+			return SensitiveValue
+		}
+		return value
+	case map[string]interface{}:
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		masked := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			masked[key] = MaskSensitive(item, s[key])
+		}
+		return masked
+	case []interface{}:
+		v, ok := value.([]interface{})
+		if !ok {
+			return value
+		}
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			if i < len(s) {
+				masked[i] = MaskSensitive(item, s[i])
+			} else {
+				masked[i] = item
+			}
+		}
+		return masked
+	default:
+		return value
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrPlanNotFound is returned when no saved plan exists for a run.
+var ErrPlanNotFound = errors.New("saved plan not found")
+
+// PlanStore keeps the saved plan file of each run from the end of planning
+// until the run is applied or discarded, so that an approval applies exactly
+// the plan reviewers looked at.
+type PlanStore interface {
+	Save(runID string, r io.Reader) error
+	Open(runID string) (io.ReadCloser, error)
+	Delete(runID string) error
+}
+
+// LocalPlanStore stores plan files on disk as <dir>/<runID>.tfplan.
+type LocalPlanStore struct {
+	dir string
+}
+
+func NewLocalPlanStore(dir string) *LocalPlanStore {
+	return &LocalPlanStore{dir: dir}
+}
+
+func (s *LocalPlanStore) Save(runID string, r io.Reader) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, runID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(runID))
+}
+
+func (s *LocalPlanStore) Open(runID string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(runID))
+	if os.IsNotExist(err) {
+		return nil, ErrPlanNotFound
+	}
+	return f, err
+}
+
+func (s *LocalPlanStore) Delete(runID string) error {
+	err := os.Remove(s.path(runID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalPlanStore) path(runID string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s.tfplan", filepath.Base(runID)))
+}
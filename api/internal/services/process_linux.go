@@ -0,0 +1,84 @@
+//go:build linux
+
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalRunProcesses sends sig to the server's child processes whose working
+// directory is inside dir and returns how many were signalled. With group
+// set, children that lead their own process group (terraform does) are
+// signalled as a group, which also reaches provider plugins.
+func signalRunProcesses(dir string, sig syscall.Signal, group bool) (int, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, err
+	}
+
+	self := os.Getpid()
+	signalled := 0
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if parentPID(pid) != self {
+			continue
+		}
+
+		cwd, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, cwd); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+
+		target := pid
+		if group {
+			if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+				target = -pid
+			}
+		}
+		if err := syscall.Kill(target, sig); err == nil {
+			signalled++
+		}
+	}
+
+	return signalled, nil
+}
+
+// parentPID reads the parent of a process from /proc/<pid>/stat. The command
+// name may contain spaces and parentheses, so fields are read after the last
+// closing parenthesis.
+func parentPID(pid int) int {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return -1
+	}
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return -1
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 2 {
+		return -1
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return -1
+	}
+	return ppid
+}
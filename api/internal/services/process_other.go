@@ -0,0 +1,14 @@
+//go:build !linux
+
+package services
+
+import (
+	"errors"
+	"syscall"
+)
+
+// signalRunProcesses is only implemented on Linux. Elsewhere the executor
+// falls back to cancelling the run's context, which kills terraform outright.
+func signalRunProcesses(dir string, sig syscall.Signal, group bool) (int, error) {
+	return 0, errors.New("signalling run processes is not supported on this platform")
+}
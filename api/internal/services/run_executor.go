@@ -0,0 +1,918 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/terraconsole/api/internal/config"
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrStalePlan is returned when a saved plan no longer matches the
+// workspace state it was made against.
+var ErrStalePlan = errors.New("plan is stale")
+
+// errRunCancelled stops a run between terraform commands once a user has
+// cancelled it.
+var errRunCancelled = errors.New("run was cancelled")
+
+const (
+	runPollInterval  = 5 * time.Second
+	logFlushInterval = 2 * time.Second
+
+	planFileName  = "tfplan"
+	stateFileName = "terraform.tfstate"
+	erroredState  = "errored.tfstate"
+	overrideFile  = "terraconsole_override.tf"
+	tfvarsFile    = "terraconsole.auto.tfvars"
+)
+
+// backendOverride forces local state inside the run directory. The executor
+// seeds it from the workspace's current state version and records the result.
+// It also replaces the cloud block of configuration uploaded by terraform's
+// cloud backend.
+const backendOverride = `terraform {
+  backend "local" {
+    path = "terraform.tfstate"
+  }
+}
+`
+
+// passthroughEnv lists the server environment variables terraform needs to
+// work at all. Everything else (database URL, secrets) is withheld.
+var passthroughEnv = []string{"PATH", "HOME", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "SSL_CERT_FILE", "SSL_CERT_DIR"}
+
+// RunExecutor picks up queued runs and drives them through terraform init,
+// plan and apply, walking the run status machine and recording logs and state.
+// Replicas share the work through a RunQueue.
+type RunExecutor struct {
+	db        *gorm.DB
+	cfg       *config.Config
+	encryptor *EncryptionService
+	plans     PlanStore
+	blobs     BlobStore
+	states    *StateService
+	queue     *RunQueue
+	workerID  string
+
+	mu     sync.Mutex
+	live   map[string]*RunLog
+	active map[string]*activeRun
+}
+
+// activeRun is a run executing in this process.
+type activeRun struct {
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+func NewRunExecutor(db *gorm.DB, cfg *config.Config, enc *EncryptionService, plans PlanStore, blobs BlobStore, states *StateService, queue *RunQueue) *RunExecutor {
+	return &RunExecutor{
+		db:        db,
+		cfg:       cfg,
+		encryptor: enc,
+		plans:     plans,
+		blobs:     blobs,
+		states:    states,
+		queue:     queue,
+		workerID:  newWorkerID(),
+		live:      make(map[string]*RunLog),
+		active:    make(map[string]*activeRun),
+	}
+}
+
+// Start launches the worker pool along with the heartbeat, the reaper that
+// takes over runs of dead workers and the cancel subscriber. It returns
+// immediately; everything stops when ctx is cancelled.
+func (e *RunExecutor) Start(ctx context.Context) {
+	// Workers must be known alive before they claim anything, or another
+	// replica would requeue their processing list.
+	if err := e.queue.Heartbeat(ctx, e.workerID, nil); err != nil {
+		log.Printf("Run executor heartbeat failed: %v", err)
+	}
+
+	workers := e.cfg.RunWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go e.worker(ctx)
+	}
+	go e.heartbeat(ctx)
+	go e.reap(ctx)
+	go e.queue.SubscribeCancel(ctx, func(msg cancelMessage) {
+		e.cancelLocal(msg.RunID, msg.WorkspaceID, msg.Force)
+	})
+
+	log.Printf("Run executor %s started with %d workers", e.workerID, workers)
+}
+
+// Dispatch starts queued runs whose workspace is free, as far as the
+// concurrency limits allow. Runs that do not fit wait for a later pass, which
+// happens whenever a run finishes and periodically.
+func (e *RunExecutor) Dispatch() {
+	e.dispatch(context.Background())
+}
+
+// Cleanup removes the working directory and saved plan of a run that will
+// not be applied (again) and hands its workspace to the next queued run.
+func (e *RunExecutor) Cleanup(run *models.Run) {
+	os.RemoveAll(e.runDir(run))
+	if err := e.plans.Delete(run.ID); err != nil {
+		log.Printf("Run %s: failed to delete saved plan: %v", run.ID, err)
+	}
+
+	e.dispatch(context.Background())
+}
+
+// Cancel interrupts a run on whichever replica executes it. Terraform
+// receives SIGINT and stops at the next safe point, writing out the state it
+// has; when no terraform process is running, the run's context is cancelled
+// instead so the next step never starts. It reports false when no worker is
+// executing the run.
+func (e *RunExecutor) Cancel(run *models.Run) bool {
+	return e.requestCancel(run, false)
+}
+
+// ForceCancel kills the terraform process group of a run on whichever
+// replica executes it. State that terraform had not written yet is lost;
+// whatever it did write is still recorded. It reports false when no worker
+// is executing the run.
+func (e *RunExecutor) ForceCancel(run *models.Run) bool {
+	return e.requestCancel(run, true)
+}
+
+func (e *RunExecutor) requestCancel(run *models.Run, force bool) bool {
+	if e.cancelLocal(run.ID, run.WorkspaceID, force) {
+		return true
+	}
+
+	ctx := context.Background()
+	leased, err := e.queue.HasLease(ctx, run.ID)
+	if err != nil || !leased {
+		return false
+	}
+	// The worker also finds the request in the database when it misses the
+	// message, since handlers record it before cancelling.
+	if err := e.queue.PublishCancel(ctx, cancelMessage{RunID: run.ID, WorkspaceID: run.WorkspaceID, Force: force}); err != nil {
+		log.Printf("Run %s: failed to publish cancel: %v", run.ID, err)
+	}
+	return true
+}
+
+// cancelLocal cancels a run executing in this process and reports false when
+// it is not.
+func (e *RunExecutor) cancelLocal(runID, workspaceID string, force bool) bool {
+	active := e.markCancelled(runID)
+	if active == nil {
+		return false
+	}
+
+	dir := e.runDir(&models.Run{ID: runID, WorkspaceID: workspaceID})
+	if force {
+		if _, err := signalRunProcesses(dir, syscall.SIGKILL, true); err != nil {
+			log.Printf("Run %s: failed to kill terraform: %v", runID, err)
+		}
+		active.cancel()
+		return true
+	}
+
+	n, err := signalRunProcesses(dir, syscall.SIGINT, false)
+	if err != nil || n == 0 {
+		active.cancel()
+	}
+	return true
+}
+
+func (e *RunExecutor) markCancelled(runID string) *activeRun {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	active := e.active[runID]
+	if active != nil {
+		active.cancelled = true
+	}
+	return active
+}
+
+func (e *RunExecutor) cancelRequested(runID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	active := e.active[runID]
+	return active != nil && active.cancelled
+}
+
+// checkCancelled is called between terraform commands: SIGINT only stops the
+// command that is running.
+func (e *RunExecutor) checkCancelled(run *models.Run) error {
+	if e.cancelRequested(run.ID) {
+		return errRunCancelled
+	}
+	return nil
+}
+
+// CheckPlanCurrent verifies that the workspace state has not moved since the
+// run was planned. Applying a plan made against older state would silently
+// undo or conflict with whatever changed it.
+func (e *RunExecutor) CheckPlanCurrent(run *models.Run) error {
+	current, err := CurrentState(e.db, run.WorkspaceID, "id", "serial", "lineage")
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if run.PlanStateSerial != nil {
+			return fmt.Errorf("%w: workspace state was removed after planning", ErrStalePlan)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if run.PlanStateSerial == nil {
+		return fmt.Errorf("%w: workspace state was created after planning (now serial %d)", ErrStalePlan, current.Serial)
+	}
+	if current.Lineage != run.PlanStateLineage {
+		return fmt.Errorf("%w: state lineage changed from %s to %s", ErrStalePlan, run.PlanStateLineage, current.Lineage)
+	}
+	if current.Serial != *run.PlanStateSerial {
+		return fmt.Errorf("%w: state serial moved from %d to %d", ErrStalePlan, *run.PlanStateSerial, current.Serial)
+	}
+	return nil
+}
+
+func (e *RunExecutor) worker(ctx context.Context) {
+	for ctx.Err() == nil {
+		runID, err := e.queue.Claim(ctx, e.workerID, runPollInterval)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Run executor: failed to claim run: %v", err)
+				time.Sleep(runPollInterval)
+			}
+			continue
+		}
+		if runID == "" {
+			continue
+		}
+
+		e.process(ctx, runID)
+
+		if err := e.queue.Done(context.Background(), e.workerID, runID); err != nil {
+			log.Printf("Run %s: failed to acknowledge: %v", runID, err)
+		}
+		// The run no longer uses capacity, whether it finished or waits for
+		// confirmation.
+		e.dispatch(context.Background())
+	}
+}
+
+func (e *RunExecutor) process(ctx context.Context, runID string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// A run dispatched twice is only executed by the worker holding its
+	// lease, and only from a queued status.
+	if ok, err := e.queue.AcquireLease(ctx, runID, e.workerID); err != nil || !ok {
+		return
+	}
+
+	e.mu.Lock()
+	e.active[runID] = &activeRun{cancel: cancel}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.active, runID)
+		e.mu.Unlock()
+		e.queue.ReleaseLease(context.Background(), runID, e.workerID)
+	}()
+
+	var run models.Run
+	if err := e.db.Preload("Workspace").First(&run, "id = ?", runID).Error; err != nil {
+		log.Printf("Run %s: failed to load: %v", runID, err)
+		return
+	}
+
+	// A cancel published before the run was registered above is only
+	// recorded in the database.
+	if run.CancelRequestedAt != nil {
+		e.markCancelled(run.ID)
+	}
+
+	switch run.Status {
+	case models.RunStatusPending:
+		now := time.Now()
+		if !e.transition(&run, models.RunStatusPending, map[string]interface{}{
+			"status":     models.RunStatusPlanning,
+			"started_at": &now,
+		}) {
+			return
+		}
+		e.plan(ctx, &run)
+	case models.RunStatusApplyQueued:
+		if !e.transition(&run, models.RunStatusApplyQueued, map[string]interface{}{"status": models.RunStatusApplying}) {
+			return
+		}
+		e.apply(ctx, &run)
+	}
+}
+
+// transition moves a run out of the expected status. It reports false when
+// the run was changed concurrently, e.g. cancelled or discarded by a user.
+func (e *RunExecutor) transition(run *models.Run, from models.RunStatus, updates map[string]interface{}) bool {
+	result := e.db.Model(&models.Run{}).Where("id = ? AND status = ?", run.ID, from).Updates(updates)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	if status, ok := updates["status"].(models.RunStatus); ok {
+		run.Status = status
+	}
+	return true
+}
+
+func (e *RunExecutor) plan(ctx context.Context, run *models.Run) {
+	runLog, closeLog := e.openLog(run.ID, LogPhasePlan)
+
+	hasChanges, err := e.runPlan(ctx, run, runLog)
+	if err != nil {
+		runLog.Printf("\nError: %s\n", err)
+	}
+	closeLog()
+
+	if err != nil {
+		e.fail(run, models.RunStatusPlanning)
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"plan_completed_at": &now}
+	switch {
+	case run.Operation == models.RunOperationPlan || !hasChanges:
+		updates["status"] = models.RunStatusPlanOnly
+		updates["completed_at"] = &now
+	case run.AutoApply:
+		updates["status"] = models.RunStatusPlanned
+	default:
+		updates["status"] = models.RunStatusNeedsConfirm
+	}
+
+	if !e.transition(run, models.RunStatusPlanning, updates) {
+		e.Cleanup(run)
+		return
+	}
+
+	switch run.Status {
+	case models.RunStatusPlanOnly:
+		e.Cleanup(run)
+	case models.RunStatusPlanned:
+		if e.transition(run, models.RunStatusPlanned, map[string]interface{}{"status": models.RunStatusApplying}) {
+			e.apply(ctx, run)
+		}
+	}
+}
+
+func (e *RunExecutor) runPlan(ctx context.Context, run *models.Run, runLog *RunLog) (bool, error) {
+	tfDir, base, err := e.prepareWorkdir(ctx, run, runLog)
+	if err != nil {
+		return false, err
+	}
+
+	tf, err := e.terraform(run, tfDir, runLog)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tf.Init(ctx); err != nil {
+		return false, err
+	}
+	if err := e.checkCancelled(run); err != nil {
+		return false, err
+	}
+
+	opts := []tfexec.PlanOption{
+		tfexec.Out(planFileName),
+		tfexec.Destroy(run.IsDestroy),
+	}
+	if run.Operation == models.RunOperationRefresh {
+		opts = append(opts, tfexec.RefreshOnly(true))
+	}
+
+	hasChanges, err := tf.Plan(ctx, opts...)
+	if err != nil {
+		return false, err
+	}
+
+	if err := e.recordPlan(ctx, tf, run); err != nil {
+		return false, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	if err := e.storePlan(run, tfDir, base); err != nil {
+		return false, fmt.Errorf("failed to store plan: %w", err)
+	}
+
+	// A cancel that arrived as terraform finished must still stop the run
+	// from being applied.
+	if err := e.checkCancelled(run); err != nil {
+		return false, err
+	}
+
+	return hasChanges, nil
+}
+
+// storePlan saves the plan file along with the state it was made against.
+func (e *RunExecutor) storePlan(run *models.Run, tfDir string, base *models.StateVersion) error {
+	data, err := os.ReadFile(filepath.Join(tfDir, planFileName))
+	if err != nil {
+		return err
+	}
+
+	if err := e.plans.Save(run.ID, bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	run.PlanHash = fmt.Sprintf("%x", sha256.Sum256(data))
+	run.PlanStateSerial = nil
+	run.PlanStateLineage = ""
+	if base != nil {
+		serial := base.Serial
+		run.PlanStateSerial = &serial
+		run.PlanStateLineage = base.Lineage
+	}
+
+	return e.db.Model(&models.Run{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+		"plan_hash":          run.PlanHash,
+		"plan_state_serial":  run.PlanStateSerial,
+		"plan_state_lineage": run.PlanStateLineage,
+	}).Error
+}
+
+// restorePlan writes the stored plan file into the working directory after
+// checking it is the exact artifact produced by the plan phase.
+func (e *RunExecutor) restorePlan(run *models.Run, tfDir string) error {
+	rc, err := e.plans.Open(run.ID)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if fmt.Sprintf("%x", sha256.Sum256(data)) != run.PlanHash {
+		return fmt.Errorf("saved plan does not match the reviewed plan")
+	}
+
+	return os.WriteFile(filepath.Join(tfDir, planFileName), data, 0600)
+}
+
+// recordPlan stores the structured plan in the blob store and the resource
+// change counts derived from it on the run.
+func (e *RunExecutor) recordPlan(ctx context.Context, tf *tfexec.Terraform, run *models.Run) error {
+	plan, err := tf.ShowPlanFile(ctx, planFileName)
+	if err != nil {
+		return err
+	}
+
+	planJSON, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+
+	planJSONKey, err := e.blobs.Put(planJSON)
+	if err != nil {
+		return err
+	}
+
+	summary := SummarizePlan(plan)
+	return e.db.Model(&models.Run{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+		"plan_json":          "",
+		"plan_json_blob":     planJSONKey,
+		"resources_added":    summary.Added,
+		"resources_changed":  summary.Changed,
+		"resources_deleted":  summary.Deleted,
+		"resources_replaced": summary.Replaced,
+		"resources_imported": summary.Imported,
+	}).Error
+}
+
+func (e *RunExecutor) apply(ctx context.Context, run *models.Run) {
+	runLog, closeLog := e.openLog(run.ID, LogPhaseApply)
+
+	err := e.runApply(ctx, run, runLog)
+	if err != nil {
+		runLog.Printf("\nError: %s\n", err)
+	}
+	closeLog()
+
+	if err != nil {
+		e.fail(run, models.RunStatusApplying)
+		return
+	}
+
+	now := time.Now()
+	e.transition(run, models.RunStatusApplying, map[string]interface{}{
+		"status":       models.RunStatusApplied,
+		"applied_at":   &now,
+		"completed_at": &now,
+	})
+	e.Cleanup(run)
+}
+
+func (e *RunExecutor) runApply(ctx context.Context, run *models.Run, runLog *RunLog) error {
+	if err := e.CheckPlanCurrent(run); err != nil {
+		return err
+	}
+
+	// The working directory is gone when the plan was made by another worker
+	// or before a restart; rebuild it so providers and modules are installed.
+	tfDir := e.terraformDir(run)
+	rebuild := false
+	if _, err := os.Stat(tfDir); err != nil {
+		runLog.Printf("Restoring working directory for saved plan\n")
+		if tfDir, _, err = e.prepareWorkdir(ctx, run, runLog); err != nil {
+			return err
+		}
+		rebuild = true
+	}
+
+	if err := e.restorePlan(run, tfDir); err != nil {
+		if errors.Is(err, ErrPlanNotFound) {
+			return fmt.Errorf("plan for this run is no longer available, queue a new run")
+		}
+		return err
+	}
+
+	tf, err := e.terraform(run, tfDir, runLog)
+	if err != nil {
+		return err
+	}
+
+	if rebuild {
+		if err := tf.Init(ctx); err != nil {
+			return err
+		}
+	}
+	if err := e.checkCancelled(run); err != nil {
+		return err
+	}
+
+	applyErr := tf.Apply(ctx, tfexec.DirOrPlan(planFileName))
+
+	// A failed or cancelled apply can still have changed infrastructure, so
+	// whatever terraform managed to write is recorded either way.
+	if _, err := os.Stat(filepath.Join(tfDir, erroredState)); err == nil {
+		runLog.Printf("\nRecovering partial state from %s\n", erroredState)
+	}
+	if err := e.saveState(run, tfDir); err != nil {
+		runLog.Printf("\nFailed to save state: %s\n", err)
+		if applyErr == nil {
+			applyErr = err
+		}
+	}
+
+	return applyErr
+}
+
+func (e *RunExecutor) fail(run *models.Run, from models.RunStatus) {
+	status := models.RunStatusErrored
+	if e.cancelRequested(run.ID) {
+		status = models.RunStatusCancelled
+	}
+
+	now := time.Now()
+	e.transition(run, from, map[string]interface{}{
+		"status":       status,
+		"completed_at": &now,
+	})
+	e.Cleanup(run)
+}
+
+func (e *RunExecutor) runDir(run *models.Run) string {
+	return filepath.Join(e.cfg.WorkingDir, run.WorkspaceID, "runs", run.ID)
+}
+
+func (e *RunExecutor) configDir(workspaceID string) string {
+	return filepath.Join(e.cfg.WorkingDir, workspaceID, "config")
+}
+
+func (e *RunExecutor) terraformDir(run *models.Run) string {
+	return filepath.Join(e.runDir(run), "src", filepath.Clean("/"+run.Workspace.WorkingDirectory))
+}
+
+// prepareWorkdir lays out a fresh copy of the run's configuration, the
+// current state, variables and the backend override for a run. The
+// configuration comes from an uploaded configuration version, the
+// workspace's repository or its config directory, in that order.
+func (e *RunExecutor) prepareWorkdir(ctx context.Context, run *models.Run, runLog *RunLog) (string, *models.StateVersion, error) {
+	runDir := e.runDir(run)
+	srcDir := filepath.Join(runDir, "src")
+
+	os.RemoveAll(runDir)
+	if err := os.MkdirAll(runDir, 0700); err != nil {
+		return "", nil, fmt.Errorf("failed to create working directory: %w", err)
+	}
+
+	ws := run.Workspace
+	if run.ConfigurationVersionID != nil {
+		var cv models.ConfigurationVersion
+		if err := e.db.First(&cv, "id = ?", *run.ConfigurationVersionID).Error; err != nil {
+			return "", nil, fmt.Errorf("failed to load configuration version: %w", err)
+		}
+		if cv.Status != models.ConfigurationUploaded {
+			return "", nil, fmt.Errorf("configuration version %s was not uploaded", cv.ID)
+		}
+		runLog.Printf("Unpacking uploaded configuration %s\n", cv.ID)
+		archive, err := e.blobs.Get(cv.ArchiveBlob)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read configuration: %w", err)
+		}
+		if err := ExtractConfiguration(archive, srcDir); err != nil {
+			return "", nil, fmt.Errorf("failed to unpack configuration: %w", err)
+		}
+	} else if ws.VCSRepoURL != "" {
+		if err := ValidateVCSRepoURL(ws.VCSRepoURL); err != nil {
+			return "", nil, err
+		}
+		runLog.Printf("Cloning %s (branch %s)\n", ws.VCSRepoURL, ws.VCSBranch)
+		cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", "--branch", ws.VCSBranch, "--", ws.VCSRepoURL, srcDir)
+		cmd.Env = append(passthroughEnviron(), "GIT_TERMINAL_PROMPT=0", "GIT_ALLOW_PROTOCOL=https:ssh")
+		cmd.Stdout = runLog
+		cmd.Stderr = runLog
+		if err := cmd.Run(); err != nil {
+			return "", nil, fmt.Errorf("failed to clone repository: %w", err)
+		}
+	} else {
+		configDir := e.configDir(ws.ID)
+		if _, err := os.Stat(configDir); err != nil {
+			return "", nil, fmt.Errorf("workspace has no VCS repository and no configuration in %s", configDir)
+		}
+		if err := copyDir(configDir, srcDir); err != nil {
+			return "", nil, fmt.Errorf("failed to copy configuration: %w", err)
+		}
+	}
+
+	tfDir := e.terraformDir(run)
+	if _, err := os.Stat(tfDir); err != nil {
+		return "", nil, fmt.Errorf("working directory %q not found in configuration", ws.WorkingDirectory)
+	}
+
+	if err := os.WriteFile(filepath.Join(tfDir, overrideFile), []byte(backendOverride), 0600); err != nil {
+		return "", nil, err
+	}
+
+	current, err := CurrentState(e.db, ws.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tfDir, nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load current state: %w", err)
+	}
+	data, err := e.states.Data(current)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := os.WriteFile(filepath.Join(tfDir, stateFileName), data, 0600); err != nil {
+		return "", nil, err
+	}
+
+	return tfDir, current, nil
+}
+
+// terraform builds a tfexec handle for the run's binary with variables and
+// output wired up.
+func (e *RunExecutor) terraform(run *models.Run, tfDir string, runLog *RunLog) (*tfexec.Terraform, error) {
+	binary, err := ResolveTerraformBinary(e.cfg.TerraformDir, run.TerraformVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	tf, err := tfexec.NewTerraform(tfDir, binary)
+	if err != nil {
+		return nil, err
+	}
+	tf.SetStdout(runLog)
+	tf.SetStderr(runLog)
+
+	variables, err := e.collectVariables(&run.Workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string)
+	for _, key := range passthroughEnv {
+		if val := os.Getenv(key); val != "" {
+			env[key] = val
+		}
+	}
+
+	// terraform_remote_state with the http backend authenticates with these
+	// unless the configuration sets credentials itself.
+	runToken, err := NewRunToken(e.cfg.JWTSecret, run)
+	if err != nil {
+		return nil, err
+	}
+	env["TF_HTTP_USERNAME"] = "run"
+	env["TF_HTTP_PASSWORD"] = runToken
+
+	var tfvars strings.Builder
+	for _, v := range variables {
+		switch v.Category {
+		case models.VariableCategoryEnv:
+			env[v.Key] = v.Value
+		default:
+			fmt.Fprintf(&tfvars, "%s = %s\n", v.Key, hclValue(v))
+		}
+	}
+
+	for _, key := range tfexec.ProhibitedEnv(env) {
+		runLog.Printf("Ignoring environment variable %s: it is managed by TerraConsole\n", key)
+		delete(env, key)
+	}
+	if err := tf.SetEnv(env); err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(tfDir, tfvarsFile), []byte(tfvars.String()), 0600); err != nil {
+		return nil, err
+	}
+
+	return tf, nil
+}
+
+// collectVariables merges variable sets that apply to the workspace with the
+// workspace's own variables, which take precedence. Sensitive values are
+// returned decrypted.
+func (e *RunExecutor) collectVariables(ws *models.Workspace) ([]models.Variable, error) {
+	var project models.Project
+	if err := e.db.First(&project, "id = ?", ws.ProjectID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load project: %w", err)
+	}
+
+	var sets []models.VariableSet
+	e.db.Preload("Variables").
+		Where("organization_id = ? AND (global = ? OR id IN (?))", project.OrganizationID, true,
+			e.db.Model(&models.VariableSetWorkspace{}).Select("variable_set_id").Where("workspace_id = ?", ws.ID)).
+		Order("created_at ASC").
+		Find(&sets)
+
+	var wsVars []models.Variable
+	e.db.Where("workspace_id = ?", ws.ID).Find(&wsVars)
+
+	merged := make(map[string]models.Variable)
+	var order []string
+	add := func(v models.Variable) {
+		key := string(v.Category) + "/" + v.Key
+		if _, ok := merged[key]; !ok {
+			order = append(order, key)
+		}
+		merged[key] = v
+	}
+
+	for _, set := range sets {
+		for _, sv := range set.Variables {
+			add(models.Variable{
+				Key:       sv.Key,
+				Value:     sv.Value,
+				Category:  sv.Category,
+				HCL:       sv.HCL,
+				Sensitive: sv.Sensitive,
+			})
+		}
+	}
+	for _, v := range wsVars {
+		add(v)
+	}
+
+	variables := make([]models.Variable, 0, len(order))
+	for _, key := range order {
+		v := merged[key]
+		if v.Sensitive {
+			value, err := e.encryptor.Decrypt(v.Value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt variable %s: %w", v.Key, err)
+			}
+			v.Value = value
+		}
+		variables = append(variables, v)
+	}
+
+	return variables, nil
+}
+
+// saveState records the state terraform left in the run directory as a new
+// state version when it differs from the workspace's current state. It is
+// subject to the same serial and lineage checks as any other upload. When
+// terraform could not persist state, e.g. because it was interrupted, it
+// writes errored.tfstate instead, which is newer and takes precedence.
+func (e *RunExecutor) saveState(run *models.Run, tfDir string) error {
+	path := filepath.Join(tfDir, stateFileName)
+	if _, err := os.Stat(filepath.Join(tfDir, erroredState)); err == nil {
+		path = filepath.Join(tfDir, erroredState)
+	}
+
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	runID := run.ID
+	_, err = e.states.Save(body, StateWrite{
+		WorkspaceID: run.WorkspaceID,
+		RunID:       &runID,
+		CreatedBy:   run.CreatedBy,
+	})
+	return err
+}
+
+// hclValue renders a variable for a .tfvars file. Non-HCL values are written
+// as quoted strings with template sequences escaped.
+func hclValue(v models.Variable) string {
+	if v.HCL {
+		return v.Value
+	}
+	quoted, _ := json.Marshal(v.Value)
+	value := strings.ReplaceAll(string(quoted), "${", "$${")
+	return strings.ReplaceAll(value, "%{", "%%{")
+}
+
+// scpLikeURL matches the user@host:path form of SSH repository URLs.
+var scpLikeURL = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^:]+$`)
+
+// ValidateVCSRepoURL accepts the https and ssh repository URLs workspaces
+// may clone from. Other transports, such as file:// or local paths, would
+// let a workspace read the API host's filesystem.
+func ValidateVCSRepoURL(raw string) error {
+	if scpLikeURL.MatchString(raw) {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "ssh") || u.Host == "" || strings.HasPrefix(u.Host, "-") {
+		return fmt.Errorf("VCS repository URL must be an https or ssh URL")
+	}
+	return nil
+}
+
+func passthroughEnviron() []string {
+	var env []string
+	for _, key := range passthroughEnv {
+		if val := os.Getenv(key); val != "" {
+			env = append(env, key+"="+val)
+		}
+	}
+	return env
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+// Log phases of a run, each backed by its own log column.
+const (
+	LogPhasePlan  = "plan"
+	LogPhaseApply = "apply"
+)
+
+// LogColumn returns the runs column holding the log of a phase.
+func LogColumn(phase string) string {
+	if phase == LogPhaseApply {
+		return "apply_log"
+	}
+	return "plan_log"
+}
+
+// RunLog collects terraform output for a single run phase and notifies
+// followers whenever output is appended.
+type RunLog struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	updated chan struct{}
+}
+
+func newRunLog() *RunLog {
+	return &RunLog{updated: make(chan struct{})}
+}
+
+func (l *RunLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n, err := l.buf.Write(p)
+	close(l.updated)
+	l.updated = make(chan struct{})
+	return n, err
+}
+
+func (l *RunLog) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(l, format, args...)
+}
+
+func (l *RunLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+// Since returns the output after the given byte offset and a channel that is
+// closed when more output is written.
+func (l *RunLog) Since(offset int) ([]byte, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data := l.buf.Bytes()
+	if offset > len(data) {
+		offset = len(data)
+	}
+	chunk := make([]byte, len(data)-offset)
+	copy(chunk, data[offset:])
+	return chunk, l.updated
+}
+
+// LiveLog returns the log of a run phase that is currently executing in this
+// process, or nil when it is not.
+func (e *RunExecutor) LiveLog(runID, phase string) *RunLog {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.live[runID+"/"+phase]
+}
+
+// openLog registers a live log for a run phase and periodically writes it to
+// the phase's log column so it survives the process. The returned func writes
+// the final output, moves it to the blob store and unregisters the live log,
+// in that order, so followers never observe a gap.
+func (e *RunExecutor) openLog(runID, phase string) (*RunLog, func()) {
+	runLog := newRunLog()
+	key := runID + "/" + phase
+
+	e.mu.Lock()
+	e.live[key] = runLog
+	e.mu.Unlock()
+
+	column := LogColumn(phase)
+	flush := func() {
+		e.db.Model(&models.Run{}).Where("id = ?", runID).Updates(map[string]interface{}{
+			column:           runLog.String(),
+			column + "_blob": "",
+		})
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(logFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+
+	return runLog, func() {
+		close(done)
+		wg.Wait()
+		flush()
+		if err := storeRunText(e.db, e.blobs, runID, column, runLog.String()); err != nil {
+			log.Printf("Run %s: failed to move %s log to blob store: %v", runID, phase, err)
+		}
+
+		e.mu.Lock()
+		delete(e.live, key)
+		e.mu.Unlock()
+	}
+}
+
+// LoadRunText reads a large text column of a run, plan_log, apply_log or
+// plan_json, from the blob store once it has been moved there.
+func LoadRunText(db *gorm.DB, blobs BlobStore, runID, column string) (string, error) {
+	var row struct {
+		Text string
+		Blob string
+	}
+	err := db.Model(&models.Run{}).
+		Select("COALESCE("+column+", '') AS text, COALESCE("+column+"_blob, '') AS blob").
+		Where("id = ?", runID).
+		Take(&row).Error
+	if err != nil || row.Blob == "" {
+		return row.Text, err
+	}
+
+	data, err := blobs.Get(row.Blob)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s of run %s: %w", column, runID, err)
+	}
+	return string(data), nil
+}
+
+// storeRunText moves a large text column of a run to the blob store. The
+// column is only cleared once the blob is written, so readers see the text
+// in one place or the other.
+func storeRunText(db *gorm.DB, blobs BlobStore, runID, column, text string) error {
+	if text == "" {
+		return nil
+	}
+	key, err := blobs.Put([]byte(text))
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.Run{}).Where("id = ?", runID).Updates(map[string]interface{}{
+		column:           "",
+		column + "_blob": key,
+	}).Error
+}
+
+// MoveRunTextToBlobStore moves the logs and plans of runs stored in the runs
+// table to the blob store and returns how many it moved. Output of phases
+// that are still executing is left alone; it moves when the phase ends.
+func MoveRunTextToBlobStore(db *gorm.DB, blobs BlobStore) (int, error) {
+	executing := []models.RunStatus{models.RunStatusPending, models.RunStatusPlanning, models.RunStatusApplying}
+
+	count := 0
+	for _, column := range []string{"plan_log", "apply_log", "plan_json"} {
+		for {
+			var runs []struct {
+				ID   string
+				Text string
+			}
+			err := db.Model(&models.Run{}).
+				Select("id, "+column+" AS text").
+				Where(column+" <> '' AND status NOT IN ?", executing).
+				Order("id").
+				Limit(100).
+				Find(&runs).Error
+			if err != nil {
+				return count, err
+			}
+			if len(runs) == 0 {
+				break
+			}
+
+			for _, run := range runs {
+				key, err := blobs.Put([]byte(run.Text))
+				if err != nil {
+					return count, fmt.Errorf("failed to store %s of run %s: %w", column, run.ID, err)
+				}
+				result := db.Model(&models.Run{}).
+					Where("id = ? AND status NOT IN ?", run.ID, executing).
+					Updates(map[string]interface{}{
+						column:           "",
+						column + "_blob": key,
+					})
+				if result.Error != nil {
+					return count, result.Error
+				}
+				count += int(result.RowsAffected)
+			}
+		}
+	}
+	return count, nil
+}
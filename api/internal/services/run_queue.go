@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	queuePrefix      = "terraconsole:"
+	readyQueueKey    = queuePrefix + "runs:ready"
+	cancelChannel    = queuePrefix + "runs:cancel"
+	processingPrefix = queuePrefix + "runs:processing:"
+
+	// runLeaseTTL is how long a run stays claimed by a worker that stopped
+	// heartbeating before another replica reclaims it.
+	runLeaseTTL       = 30 * time.Second
+	heartbeatInterval = 10 * time.Second
+	lockTTL           = 10 * time.Second
+)
+
+// deleteIfOwner removes a key only while it still holds the caller's value.
+var deleteIfOwner = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// renewIfOwner extends a key's expiry only while it still holds the
+// caller's value.
+var renewIfOwner = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// RunQueue coordinates run execution between API replicas through Redis.
+// Dispatched run IDs wait in a ready list; a worker moves one into its own
+// processing list and holds a lease on the run while executing it.
+type RunQueue struct {
+	rdb *redis.Client
+}
+
+func NewRunQueue(rdb *redis.Client) *RunQueue {
+	return &RunQueue{rdb: rdb}
+}
+
+// cancelMessage asks the replica executing a run to cancel it.
+type cancelMessage struct {
+	RunID       string `json:"run_id"`
+	WorkspaceID string `json:"workspace_id"`
+	Force       bool   `json:"force"`
+}
+
+// newWorkerID returns a unique identity for this replica's workers.
+func newWorkerID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%s", host, randomToken())
+}
+
+func randomToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Push appends a run to the ready list.
+func (q *RunQueue) Push(ctx context.Context, runID string) error {
+	return q.rdb.RPush(ctx, readyQueueKey, runID).Err()
+}
+
+// Claim blocks up to timeout for a ready run and moves it to the worker's
+// processing list, so it is not lost if the worker dies before finishing.
+// It returns an empty ID when nothing became ready.
+func (q *RunQueue) Claim(ctx context.Context, workerID string, timeout time.Duration) (string, error) {
+	runID, err := q.rdb.BLMove(ctx, readyQueueKey, processingPrefix+workerID, "LEFT", "RIGHT", timeout).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return runID, err
+}
+
+// Done removes a run from the worker's processing list.
+func (q *RunQueue) Done(ctx context.Context, workerID, runID string) error {
+	return q.rdb.LRem(ctx, processingPrefix+workerID, 1, runID).Err()
+}
+
+// AcquireLease claims a run for a worker. It reports false when another
+// worker holds the run.
+func (q *RunQueue) AcquireLease(ctx context.Context, runID, workerID string) (bool, error) {
+	return q.rdb.SetNX(ctx, leaseKey(runID), workerID, runLeaseTTL).Result()
+}
+
+// ReleaseLease gives up a worker's claim on a run.
+func (q *RunQueue) ReleaseLease(ctx context.Context, runID, workerID string) error {
+	return deleteIfOwner.Run(ctx, q.rdb, []string{leaseKey(runID)}, workerID).Err()
+}
+
+// HasLease reports whether some worker is executing a run.
+func (q *RunQueue) HasLease(ctx context.Context, runID string) (bool, error) {
+	n, err := q.rdb.Exists(ctx, leaseKey(runID)).Result()
+	return n > 0, err
+}
+
+// Heartbeat marks the worker alive and renews its leases.
+func (q *RunQueue) Heartbeat(ctx context.Context, workerID string, runIDs []string) error {
+	pipe := q.rdb.Pipeline()
+	pipe.Set(ctx, workerKey(workerID), time.Now().Unix(), runLeaseTTL)
+	for _, id := range runIDs {
+		renewIfOwner.Eval(ctx, pipe, []string{leaseKey(id)}, workerID, runLeaseTTL.Milliseconds())
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RequeueOrphaned moves runs left in the processing lists of workers that
+// stopped heartbeating back to the ready list.
+func (q *RunQueue) RequeueOrphaned(ctx context.Context) (int, error) {
+	requeued := 0
+	iter := q.rdb.Scan(ctx, 0, processingPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		workerID := strings.TrimPrefix(key, processingPrefix)
+		alive, err := q.rdb.Exists(ctx, workerKey(workerID)).Result()
+		if err != nil {
+			return requeued, err
+		}
+		if alive > 0 {
+			continue
+		}
+		for {
+			_, err := q.rdb.LMove(ctx, key, readyQueueKey, "LEFT", "RIGHT").Result()
+			if errors.Is(err, redis.Nil) {
+				break
+			}
+			if err != nil {
+				return requeued, err
+			}
+			requeued++
+		}
+	}
+	return requeued, iter.Err()
+}
+
+// Dispatched returns the runs handed to workers that have not finished with
+// them: those waiting in the ready list and those in processing lists.
+func (q *RunQueue) Dispatched(ctx context.Context) (map[string]bool, error) {
+	dispatched := make(map[string]bool)
+
+	ready, err := q.rdb.LRange(ctx, readyQueueKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ready {
+		dispatched[id] = true
+	}
+
+	iter := q.rdb.Scan(ctx, 0, processingPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		ids, err := q.rdb.LRange(ctx, iter.Val(), 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			dispatched[id] = true
+		}
+	}
+	return dispatched, iter.Err()
+}
+
+// Lock takes a named lock shared by all replicas. It reports false when
+// another replica holds it.
+func (q *RunQueue) Lock(ctx context.Context, name string) (func(), bool) {
+	token := randomToken()
+	key := queuePrefix + "locks:" + name
+	ok, err := q.rdb.SetNX(ctx, key, token, lockTTL).Result()
+	if err != nil || !ok {
+		return nil, false
+	}
+	return func() {
+		deleteIfOwner.Run(context.Background(), q.rdb, []string{key}, token)
+	}, true
+}
+
+// PublishCancel forwards a cancel request to every replica.
+func (q *RunQueue) PublishCancel(ctx context.Context, msg cancelMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return q.rdb.Publish(ctx, cancelChannel, data).Err()
+}
+
+// SubscribeCancel delivers cancel requests published by any replica until
+// ctx is cancelled.
+func (q *RunQueue) SubscribeCancel(ctx context.Context, handle func(cancelMessage)) {
+	sub := q.rdb.Subscribe(ctx, cancelChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var msg cancelMessage
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err == nil {
+				handle(msg)
+			}
+		}
+	}
+}
+
+func leaseKey(runID string) string {
+	return queuePrefix + "runs:" + runID + ":lease"
+}
+
+func workerKey(workerID string) string {
+	return queuePrefix + "workers:" + workerID
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/terraconsole/api/internal/models"
+)
+
+// occupyingStatuses are the statuses in which a run holds its workspace: from
+// the start of planning until it finishes, including while it waits for
+// confirmation.
+var occupyingStatuses = []models.RunStatus{
+	models.RunStatusPlanning,
+	models.RunStatusPlanned,
+	models.RunStatusNeedsConfirm,
+	models.RunStatusApplyQueued,
+	models.RunStatusApplying,
+}
+
+// QueuedRun is a run that uses or waits for execution capacity.
+type QueuedRun struct {
+	ID             string           `json:"id"`
+	WorkspaceID    string           `json:"workspace_id"`
+	WorkspaceName  string           `json:"workspace_name"`
+	ProjectID      string           `json:"project_id"`
+	OrganizationID string           `json:"organization_id"`
+	Status         models.RunStatus `json:"status"`
+	QueuePosition  *int             `json:"queue_position,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// CapacityUsage is the number of runs executing within a scope against its
+// concurrency limit. A limit of 0 means unlimited.
+type CapacityUsage struct {
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Limit   int    `json:"limit"`
+	Running int    `json:"running"`
+	Queued  int    `json:"queued"`
+}
+
+// CapacityReport describes run capacity across all replicas.
+type CapacityReport struct {
+	Global        CapacityUsage   `json:"global"`
+	Organizations []CapacityUsage `json:"organizations"`
+	Projects      []CapacityUsage `json:"projects"`
+	Running       []*QueuedRun    `json:"running"`
+	Queued        []*QueuedRun    `json:"queued"`
+}
+
+// queueSnapshot is the scheduler's view of all queued and executing runs.
+type queueSnapshot struct {
+	running []*QueuedRun
+	waiting []*QueuedRun // in queue order
+	ready   []*QueuedRun // waiting runs that can be dispatched now
+
+	orgs     map[string]*CapacityUsage
+	projects map[string]*CapacityUsage
+	global   CapacityUsage
+}
+
+// dispatch hands waiting runs to workers, oldest first, as long as their
+// workspace is free and the global, organization and project limits allow.
+// Runs it cannot dispatch get their position in the queue recorded. Only one
+// replica dispatches at a time; the reaper retries passes that were skipped.
+func (e *RunExecutor) dispatch(ctx context.Context) {
+	unlock, ok := e.queue.Lock(ctx, "dispatch")
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	snap, err := e.snapshot(ctx)
+	if err != nil {
+		log.Printf("Run executor: failed to read queue: %v", err)
+		return
+	}
+
+	for _, run := range snap.ready {
+		if err := e.queue.Push(ctx, run.ID); err != nil {
+			log.Printf("Run %s: failed to dispatch: %v", run.ID, err)
+		}
+	}
+
+	positions := snap.positions()
+	for _, run := range snap.waiting {
+		if !samePosition(run.QueuePosition, positions[run.ID]) {
+			e.db.Model(&models.Run{}).Where("id = ?", run.ID).Update("queue_position", positions[run.ID])
+		}
+	}
+
+	e.db.Model(&models.Run{}).
+		Where("queue_position IS NOT NULL AND status NOT IN ?", []models.RunStatus{models.RunStatusPending, models.RunStatusApplyQueued}).
+		Update("queue_position", nil)
+}
+
+// Capacity reports how much of each concurrency limit is in use and which
+// runs are waiting.
+func (e *RunExecutor) Capacity(ctx context.Context) (*CapacityReport, error) {
+	snap, err := e.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CapacityReport{
+		Global:        snap.global,
+		Organizations: sortedUsage(snap.orgs),
+		Projects:      sortedUsage(snap.projects),
+		Running:       snap.running,
+		Queued:        snap.waiting,
+	}
+
+	positions := snap.positions()
+	for _, run := range snap.waiting {
+		run.QueuePosition = positions[run.ID]
+	}
+	return report, nil
+}
+
+// positions numbers the waiting runs that cannot be dispatched yet.
+func (s *queueSnapshot) positions() map[string]*int {
+	ready := make(map[string]bool, len(s.ready))
+	for _, run := range s.ready {
+		ready[run.ID] = true
+	}
+
+	positions := make(map[string]*int, len(s.waiting))
+	n := 0
+	for _, run := range s.waiting {
+		if ready[run.ID] {
+			continue
+		}
+		n++
+		p := n
+		positions[run.ID] = &p
+	}
+	return positions
+}
+
+// snapshot reads the queue and decides which waiting runs fit. Dispatched
+// runs are read from Redis before the runs are read from the database, so a
+// run a worker picks up in between is seen in one place or the other.
+func (e *RunExecutor) snapshot(ctx context.Context) (*queueSnapshot, error) {
+	dispatched, err := e.queue.Dispatched(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []*QueuedRun
+	err = e.db.Table("runs").
+		Select("runs.id, runs.workspace_id, workspaces.name AS workspace_name, workspaces.project_id, projects.organization_id, runs.status, runs.queue_position, runs.created_at").
+		Joins("JOIN workspaces ON workspaces.id = runs.workspace_id").
+		Joins("JOIN projects ON projects.id = workspaces.project_id").
+		Where("runs.status IN ?", append([]models.RunStatus{models.RunStatusPending}, occupyingStatuses...)).
+		Order("runs.created_at ASC").
+		Scan(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &queueSnapshot{
+		orgs:     make(map[string]*CapacityUsage),
+		projects: make(map[string]*CapacityUsage),
+		global:   CapacityUsage{Limit: e.cfg.MaxConcurrentRuns},
+	}
+	if err := e.loadLimits(runs, snap); err != nil {
+		return nil, err
+	}
+
+	// Runs that are executing or already handed to a worker use capacity
+	// and hold their workspace. So does an approved apply that still waits
+	// for capacity.
+	busy := make(map[string]bool)
+	for _, run := range runs {
+		queued := run.Status == models.RunStatusPending || run.Status == models.RunStatusApplyQueued
+		switch {
+		case queued && !dispatched[run.ID]:
+			snap.waiting = append(snap.waiting, run)
+			snap.orgs[run.OrganizationID].Queued++
+			snap.projects[run.ProjectID].Queued++
+			snap.global.Queued++
+			if run.Status == models.RunStatusApplyQueued {
+				busy[run.WorkspaceID] = true
+			}
+			continue
+		case run.Status != models.RunStatusNeedsConfirm:
+			snap.running = append(snap.running, run)
+			snap.orgs[run.OrganizationID].Running++
+			snap.projects[run.ProjectID].Running++
+			snap.global.Running++
+		}
+		busy[run.WorkspaceID] = true
+	}
+
+	// Walk the waiting runs in order. Only the oldest pending run of a free
+	// workspace may start; an approved apply already holds its workspace.
+	used := map[*CapacityUsage]int{&snap.global: snap.global.Running}
+	for _, u := range snap.orgs {
+		used[u] = u.Running
+	}
+	for _, u := range snap.projects {
+		used[u] = u.Running
+	}
+	for _, run := range snap.waiting {
+		if run.Status == models.RunStatusPending {
+			if busy[run.WorkspaceID] {
+				continue
+			}
+			busy[run.WorkspaceID] = true
+		}
+
+		scopes := []*CapacityUsage{&snap.global, snap.orgs[run.OrganizationID], snap.projects[run.ProjectID]}
+		fits := true
+		for _, u := range scopes {
+			if u.Limit > 0 && used[u] >= u.Limit {
+				fits = false
+			}
+		}
+		if !fits {
+			continue
+		}
+		for _, u := range scopes {
+			used[u]++
+		}
+		snap.ready = append(snap.ready, run)
+	}
+
+	return snap, nil
+}
+
+// loadLimits sets up usage counters for the organizations and projects of
+// the given runs. Their own limits override the configured defaults.
+func (e *RunExecutor) loadLimits(runs []*QueuedRun, snap *queueSnapshot) error {
+	var orgIDs, projectIDs []string
+	for _, run := range runs {
+		if _, ok := snap.orgs[run.OrganizationID]; !ok {
+			snap.orgs[run.OrganizationID] = &CapacityUsage{ID: run.OrganizationID, Limit: e.cfg.MaxConcurrentRunsPerOrg}
+			orgIDs = append(orgIDs, run.OrganizationID)
+		}
+		if _, ok := snap.projects[run.ProjectID]; !ok {
+			snap.projects[run.ProjectID] = &CapacityUsage{ID: run.ProjectID, Limit: e.cfg.MaxConcurrentRunsPerProject}
+			projectIDs = append(projectIDs, run.ProjectID)
+		}
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+
+	var orgs []models.Organization
+	if err := e.db.Select("id, name, max_concurrent_runs").Where("id IN ?", orgIDs).Find(&orgs).Error; err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		u := snap.orgs[org.ID]
+		u.Name = org.Name
+		if org.MaxConcurrentRuns > 0 {
+			u.Limit = org.MaxConcurrentRuns
+		}
+	}
+
+	var projects []models.Project
+	if err := e.db.Select("id, name, max_concurrent_runs").Where("id IN ?", projectIDs).Find(&projects).Error; err != nil {
+		return err
+	}
+	for _, project := range projects {
+		u := snap.projects[project.ID]
+		u.Name = project.Name
+		if project.MaxConcurrentRuns > 0 {
+			u.Limit = project.MaxConcurrentRuns
+		}
+	}
+	return nil
+}
+
+func sortedUsage(m map[string]*CapacityUsage) []CapacityUsage {
+	usage := make([]CapacityUsage, 0, len(m))
+	for _, u := range m {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage
+}
+
+func samePosition(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// heartbeat keeps this replica's workers alive and their leases held.
+func (e *RunExecutor) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		e.mu.Lock()
+		runIDs := make([]string, 0, len(e.active))
+		for id := range e.active {
+			runIDs = append(runIDs, id)
+		}
+		e.mu.Unlock()
+
+		if err := e.queue.Heartbeat(ctx, e.workerID, runIDs); err != nil {
+			log.Printf("Run executor heartbeat failed: %v", err)
+		}
+	}
+}
+
+// reap periodically repairs the queue and dispatches. Every replica runs
+// it; all changes are conditional, so they do not step on each other.
+func (e *RunExecutor) reap(ctx context.Context) {
+	ticker := time.NewTicker(runPollInterval)
+	defer ticker.Stop()
+
+	for {
+		e.reapOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *RunExecutor) reapOnce(ctx context.Context) {
+	if n, err := e.queue.RequeueOrphaned(ctx); err != nil {
+		log.Printf("Run executor: failed to requeue orphaned runs: %v", err)
+	} else if n > 0 {
+		log.Printf("Run executor: requeued %d runs of stopped workers", n)
+	}
+
+	// Runs whose worker stopped renewing its lease.
+	var running []models.Run
+	e.db.Preload("Workspace").
+		Where("status IN ?", []models.RunStatus{models.RunStatusPlanning, models.RunStatusPlanned, models.RunStatusApplying}).
+		Find(&running)
+	for i := range running {
+		if leased, err := e.queue.HasLease(ctx, running[i].ID); err != nil || leased {
+			continue
+		}
+		e.reclaim(&running[i])
+	}
+
+	// Waiting runs whose dispatch was lost (e.g. with Redis data) are
+	// dispatched again here, as is anything a skipped pass left behind.
+	e.dispatch(ctx)
+}
+
+// reclaim takes over a run whose worker died. Planning is simply started
+// again. An interrupted apply may have changed infrastructure, so it fails
+// after recording whatever state terraform left behind, when the run
+// directory is reachable from this replica.
+func (e *RunExecutor) reclaim(run *models.Run) {
+	from := run.Status
+	now := time.Now()
+
+	if from == models.RunStatusApplying || run.CancelRequestedAt != nil {
+		status := models.RunStatusErrored
+		if run.CancelRequestedAt != nil {
+			status = models.RunStatusCancelled
+		}
+		if !e.transition(run, from, map[string]interface{}{
+			"status":       status,
+			"completed_at": &now,
+		}) {
+			return
+		}
+		log.Printf("Run %s: worker lost while %s, marked %s", run.ID, from, status)
+
+		if from == models.RunStatusApplying {
+			if err := e.saveState(run, e.terraformDir(run)); err != nil {
+				log.Printf("Run %s: failed to recover state: %v", run.ID, err)
+			}
+		}
+		e.Cleanup(run)
+		return
+	}
+
+	// Back to pending, it is the oldest run of its workspace and is
+	// dispatched again first.
+	if e.transition(run, from, map[string]interface{}{"status": models.RunStatusPending}) {
+		log.Printf("Run %s: worker lost while %s, planning again", run.ID, from)
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/terraconsole/api/internal/models"
+)
+
+// RunTokenType marks the tokens runs authenticate with, in the typ claim.
+const RunTokenType = "run"
+
+// runTokenTTL bounds how long a run token stays valid; a token is issued for
+// each plan and apply.
+const runTokenTTL = 6 * time.Hour
+
+// NewRunToken issues the token a run uses to read the remote state of other
+// workspaces. It acts for the workspace of the run, not for the user who
+// started it, and is only accepted by the state backend routes.
+func NewRunToken(secret string, run *models.Run) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": run.CreatedBy,
+		"typ": RunTokenType,
+		"ws":  run.WorkspaceID,
+		"run": run.ID,
+		"exp": now.Add(runTokenTTL).Unix(),
+		"iat": now.Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+)
+
+// RefreshTokenPrefix starts every refresh token.
+const RefreshTokenPrefix = "tcr_"
+
+// refreshReuseGrace is how long the previous refresh token of a session is
+// tolerated after a rotation, for a second browser tab that refreshed at the
+// same time. It is refused without revoking the session.
+const refreshReuseGrace = 30 * time.Second
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired and revoked
+	// refresh tokens.
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+	// ErrSessionRevoked is returned for access tokens of a session that was
+	// revoked or has expired.
+	ErrSessionRevoked = errors.New("session revoked")
+)
+
+// SessionClient identifies the device a session was signed in from.
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+// NewSession signs a user in and returns the session with its first refresh
+// token.
+func NewSession(db *gorm.DB, userID string, client SessionClient, ttl time.Duration) (*models.Session, string, error) {
+	token, hash, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:           userID,
+		RefreshTokenHash: hash,
+		UserAgent:        client.UserAgent,
+		IPAddress:        client.IPAddress,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(ttl),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, "", err
+	}
+
+	// Expired sessions of the user are of no use anymore.
+	db.Where("user_id = ? AND expires_at < ?", userID, now).Delete(&models.Session{})
+
+	return &session, token, nil
+}
+
+// RotateSession exchanges a refresh token for a new one and extends its
+// session. A refresh token that was already exchanged may have been stolen,
+// so presenting it again revokes the session.
+func RotateSession(db *gorm.DB, refreshToken string, client SessionClient, ttl time.Duration, now time.Time) (*models.Session, string, error) {
+	hash := HashAPIToken(refreshToken)
+	token, nextHash, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := db.Model(&models.Session{}).
+		Where("refresh_token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hash, now).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  nextHash,
+			"previous_token_hash": hash,
+			"rotated_at":          now,
+			"last_used_at":        now,
+			"expires_at":          now.Add(ttl),
+			"user_agent":          client.UserAgent,
+			"ip_address":          client.IPAddress,
+		})
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		var reused models.Session
+		err := db.Where("previous_token_hash = ? AND revoked_at IS NULL", hash).First(&reused).Error
+		if err == nil && (reused.RotatedAt == nil || now.Sub(*reused.RotatedAt) > refreshReuseGrace) {
+			RevokeSessions(db, now, "id = ?", reused.ID)
+		}
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	var session models.Session
+	if err := db.First(&session, "refresh_token_hash = ?", nextHash).Error; err != nil {
+		return nil, "", err
+	}
+	return &session, token, nil
+}
+
+// CheckSession returns ErrSessionRevoked unless the session of an access
+// token is still active.
+func CheckSession(db *gorm.DB, sessionID, userID string, now time.Time) error {
+	var count int64
+	err := db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, now).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// RevokeSessions revokes the active sessions matching a condition and
+// returns how many it revoked.
+func RevokeSessions(db *gorm.DB, now time.Time, query string, args ...interface{}) (int64, error) {
+	result := db.Model(&models.Session{}).
+		Where(query, args...).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", now)
+	return result.RowsAffected, result.Error
+}
+
+// NewAccessToken issues the short-lived JWT a session authenticates API
+// requests with.
+func NewAccessToken(secret string, user *models.User, sessionID string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := jwt.MapClaims{
+		"sub":   user.ID,
+		"email": user.Email,
+		"sid":   sessionID,
+		"exp":   expiresAt.Unix(),
+		"iat":   now.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	return token, expiresAt, err
+}
+
+func newRefreshToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = RefreshTokenPrefix + hex.EncodeToString(b)
+	return token, HashAPIToken(token), nil
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidState is returned for uploads that are not a terraform state
+	// document the server can manage.
+	ErrInvalidState = errors.New("invalid state")
+
+	// ErrStateConflict is returned when an upload would overwrite newer state
+	// or state of another lineage.
+	ErrStateConflict = errors.New("state conflict")
+)
+
+// stateFormatVersion is the state format written by terraform 0.12 and
+// later.
+const stateFormatVersion = 4
+
+// StateFile is the part of a terraform state document the server reads.
+type StateFile struct {
+	Version          int                    `json:"version"`
+	TerraformVersion string                 `json:"terraform_version"`
+	Serial           int                    `json:"serial"`
+	Lineage          string                 `json:"lineage"`
+	Outputs          map[string]interface{} `json:"outputs"`
+}
+
+// ParseState validates a state document and returns its header.
+func ParseState(body []byte) (*StateFile, error) {
+	var state StateFile
+	if err := json.Unmarshal(body, &state); err != nil {
+		return nil, fmt.Errorf("%w: not valid JSON: %s", ErrInvalidState, err)
+	}
+	if state.Version != stateFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported state format version %d, expected %d", ErrInvalidState, state.Version, stateFormatVersion)
+	}
+	if state.Lineage == "" {
+		return nil, fmt.Errorf("%w: lineage is missing", ErrInvalidState)
+	}
+	if state.Serial < 0 {
+		return nil, fmt.Errorf("%w: serial cannot be negative", ErrInvalidState)
+	}
+	return &state, nil
+}
+
+// StateWrite describes where a state upload comes from.
+type StateWrite struct {
+	WorkspaceID string
+	RunID       *string
+	CreatedBy   string
+
+	// Force accepts state of another lineage, or an older serial, the way
+	// terraform state push -force does.
+	Force bool
+}
+
+// StateService records and reads state versions. Every write of workspace
+// state goes through it so uploads are checked against the current state the
+// same way, and state is only ever stored compressed and encrypted, in the
+// blob store.
+type StateService struct {
+	db        *gorm.DB
+	encryptor *EncryptionService
+	blobs     BlobStore
+}
+
+func NewStateService(db *gorm.DB, enc *EncryptionService, blobs BlobStore) *StateService {
+	return &StateService{db: db, encryptor: enc, blobs: blobs}
+}
+
+// Save records body as the new current state of a workspace. Uploading the
+// current state again is a no-op that returns the current version.
+func (s *StateService) Save(body []byte, write StateWrite) (*models.StateVersion, error) {
+	file, err := ParseState(body)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := fmt.Sprintf("%x", sha256.Sum256(body))
+	md5sum := fmt.Sprintf("%x", md5.Sum(body))
+	outputsJSON, _ := json.Marshal(file.Outputs)
+
+	resources, resourceCount, err := parseResources(body)
+	if err != nil {
+		return nil, err
+	}
+
+	data, dataKey, err := s.encode(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt state: %w", err)
+	}
+	// The blob is stored before the transaction, which a slow blob store
+	// would otherwise hold open, and removed again if no version uses it.
+	blobKey, err := s.blobs.Put(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store state: %w", err)
+	}
+
+	var saved *models.StateVersion
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Concurrent writers of a workspace are checked one at a time.
+		var workspace models.Workspace
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "current_state_id").First(&workspace, "id = ?", write.WorkspaceID).Error; err != nil {
+			return err
+		}
+
+		current, err := CurrentState(tx, write.WorkspaceID, "id", "serial", "lineage", "state_hash")
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if current != nil {
+			if current.StateHash == hash {
+				saved = current
+				return nil
+			}
+			if err := checkStateUpdate(current, file, write.Force); err != nil {
+				return err
+			}
+		}
+
+		state := models.StateVersion{
+			WorkspaceID:   write.WorkspaceID,
+			RunID:         write.RunID,
+			Serial:        file.Serial,
+			Lineage:       file.Lineage,
+			StateBlob:     blobKey,
+			StateSize:     len(data),
+			Encoding:      models.StateEncodingGzipAESGCM,
+			DataKey:       dataKey,
+			StateHash:     hash,
+			StateMD5:      md5sum,
+			Outputs:       string(outputsJSON),
+			ResourceCount: resourceCount,
+			CreatedBy:     write.CreatedBy,
+		}
+		if err := tx.Create(&state).Error; err != nil {
+			return err
+		}
+		if err := replaceResources(tx, write.WorkspaceID, state.ID, resources); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Workspace{}).Where("id = ?", write.WorkspaceID).Update("current_state_id", state.ID).Error; err != nil {
+			return err
+		}
+
+		saved = &state
+		return nil
+	})
+	if err != nil || saved.StateBlob != blobKey {
+		s.deleteUnusedBlob(blobKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// deleteUnusedBlob deletes a state blob that no state version refers to.
+func (s *StateService) deleteUnusedBlob(key string) {
+	var refs int64
+	if err := s.db.Model(&models.StateVersion{}).Where("state_blob = ?", key).Count(&refs).Error; err != nil || refs > 0 {
+		return
+	}
+	if err := s.blobs.Delete(key); err != nil {
+		log.Printf("Failed to delete unused state blob %s: %v", key, err)
+	}
+}
+
+// Rollback restores a historical state version by saving a copy of it as
+// the new current state, with the serial moved past the current one so
+// terraform accepts it as newer. Restoring a version of another lineage
+// requires force.
+func (s *StateService) Rollback(target *models.StateVersion, createdBy string, force bool) (*models.StateVersion, error) {
+	current, err := CurrentState(s.db, target.WorkspaceID, "id", "serial", "lineage")
+	if err != nil {
+		return nil, err
+	}
+	if current.ID == target.ID {
+		return nil, fmt.Errorf("%w: state version %s is already the current state", ErrStateConflict, target.ID)
+	}
+	if current.Lineage != target.Lineage && !force {
+		return nil, fmt.Errorf("%w: state version %s has lineage %s, the current state has %s; roll back with force to replace it", ErrStateConflict, target.ID, target.Lineage, current.Lineage)
+	}
+
+	body, err := s.Data(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidState, err)
+	}
+	doc["serial"] = json.RawMessage(strconv.Itoa(current.Serial + 1))
+	body, err = json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Save(body, StateWrite{
+		WorkspaceID: target.WorkspaceID,
+		CreatedBy:   createdBy,
+		Force:       force,
+	})
+}
+
+// Data returns the state document of a version, decrypting it as needed.
+// The version must have been loaded with its state, blob key, encoding and
+// data key.
+func (s *StateService) Data(state *models.StateVersion) ([]byte, error) {
+	stored := state.State
+	if state.StateBlob != "" {
+		var err error
+		if stored, err = s.blobs.Get(state.StateBlob); err != nil {
+			return nil, fmt.Errorf("failed to read state version %s: %w", state.ID, err)
+		}
+	}
+
+	switch state.Encoding {
+	case models.StateEncodingNone:
+		return stored, nil
+	case models.StateEncodingGzipAESGCM:
+		key, err := s.encryptor.UnwrapDataKey(state.DataKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap data key of state version %s: %w", state.ID, err)
+		}
+		compressed, err := Open(key, stored)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt state version %s: %w", state.ID, err)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("state version %s has unknown encoding %q", state.ID, state.Encoding)
+	}
+}
+
+// EncryptPlaintext rewrites state versions stored as plaintext, from before
+// state was encrypted at rest, and returns how many it rewrote.
+func (s *StateService) EncryptPlaintext() (int, error) {
+	count := 0
+	for {
+		var versions []models.StateVersion
+		err := s.db.Select("id, state").
+			Where("encoding = ? OR encoding IS NULL", models.StateEncodingNone).
+			Order("id").
+			Limit(100).
+			Find(&versions).Error
+		if err != nil {
+			return count, err
+		}
+		if len(versions) == 0 {
+			return count, nil
+		}
+
+		for _, version := range versions {
+			data, dataKey, err := s.encode(version.State)
+			if err != nil {
+				return count, fmt.Errorf("failed to encrypt state version %s: %w", version.ID, err)
+			}
+
+			result := s.db.Model(&models.StateVersion{}).
+				Where("id = ? AND (encoding = ? OR encoding IS NULL)", version.ID, models.StateEncodingNone).
+				Updates(map[string]interface{}{
+					"state":    data,
+					"encoding": models.StateEncodingGzipAESGCM,
+					"data_key": dataKey,
+				})
+			if result.Error != nil {
+				return count, result.Error
+			}
+			count += int(result.RowsAffected)
+		}
+	}
+}
+
+// MoveToBlobStore moves encrypted state still stored in the state_versions
+// table to the blob store and returns how many versions it moved. Each
+// version is switched over only once its blob is written, so reads keep
+// working throughout. Plaintext state is left for EncryptPlaintext.
+func (s *StateService) MoveToBlobStore() (int, error) {
+	count := 0
+	for {
+		var versions []models.StateVersion
+		err := s.db.Select("id, state").
+			Where("state IS NOT NULL AND encoding = ?", models.StateEncodingGzipAESGCM).
+			Order("id").
+			Limit(100).
+			Find(&versions).Error
+		if err != nil {
+			return count, err
+		}
+		if len(versions) == 0 {
+			return count, nil
+		}
+
+		for _, version := range versions {
+			key, err := s.blobs.Put(version.State)
+			if err != nil {
+				return count, fmt.Errorf("failed to store state version %s: %w", version.ID, err)
+			}
+
+			result := s.db.Model(&models.StateVersion{}).
+				Where("id = ? AND state IS NOT NULL", version.ID).
+				Updates(map[string]interface{}{
+					"state":      nil,
+					"state_blob": key,
+					"state_size": len(version.State),
+				})
+			if result.Error != nil {
+				return count, result.Error
+			}
+			count += int(result.RowsAffected)
+		}
+	}
+}
+
+// encode compresses state and encrypts it under a fresh data key, returned
+// wrapped by the master key.
+func (s *StateService) encode(body []byte) ([]byte, string, error) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(body); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+
+	key, wrapped, err := s.encryptor.NewDataKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := Seal(key, compressed.Bytes())
+	if err != nil {
+		return nil, "", err
+	}
+	return data, wrapped, nil
+}
+
+// checkStateUpdate applies terraform's rules for replacing remote state: the
+// lineage must stay the same and the serial must move forward.
+func checkStateUpdate(current *models.StateVersion, next *StateFile, force bool) error {
+	if force {
+		return nil
+	}
+	if next.Lineage != current.Lineage {
+		return fmt.Errorf("%w: state lineage %s does not match current lineage %s; push with force to replace it", ErrStateConflict, next.Lineage, current.Lineage)
+	}
+	if next.Serial <= current.Serial {
+		return fmt.Errorf("%w: state serial %d is not newer than current serial %d; pull the current state and retry", ErrStateConflict, next.Serial, current.Serial)
+	}
+	return nil
+}
+
+// CurrentState loads the state version the workspace points to. Columns
+// limits what is loaded; all columns are loaded when it is empty.
+func CurrentState(db *gorm.DB, workspaceID string, columns ...string) (*models.StateVersion, error) {
+	query := db.Where("id = (?)", db.Model(&models.Workspace{}).Select("current_state_id").Where("id = ?", workspaceID))
+	if len(columns) > 0 {
+		query = query.Select(columns)
+	}
+
+	var state models.StateVersion
+	if err := query.First(&state).Error; err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StateDiff lists what changed between two state documents. Values of
+// sensitive attributes and outputs are masked.
+type StateDiff struct {
+	Added    []InstanceDiff `json:"added"`
+	Removed  []InstanceDiff `json:"removed"`
+	Modified []InstanceDiff `json:"modified"`
+	Outputs  []OutputDiff   `json:"outputs"`
+}
+
+// InstanceDiff is a managed resource instance that differs between two
+// states. Changes is only set for modified instances.
+type InstanceDiff struct {
+	Address  string            `json:"address"`
+	Type     string            `json:"type"`
+	Provider string            `json:"provider"`
+	Changes  []AttributeChange `json:"changes,omitempty"`
+}
+
+// AttributeChange is a single changed attribute value, addressed like
+// tags.Name or ingress[0].from_port.
+type AttributeChange struct {
+	Path      string      `json:"path"`
+	Before    interface{} `json:"before"`
+	After     interface{} `json:"after"`
+	Sensitive bool        `json:"sensitive,omitempty"`
+}
+
+// OutputDiff is a root module output that was added, removed or changed.
+type OutputDiff struct {
+	Name      string      `json:"name"`
+	Action    string      `json:"action"`
+	Before    interface{} `json:"before"`
+	After     interface{} `json:"after"`
+	Sensitive bool        `json:"sensitive,omitempty"`
+}
+
+type diffInstance struct {
+	resource   *stateResource
+	attributes map[string]interface{}
+	sensitive  [][]interface{}
+}
+
+// DiffStates compares two state documents, from before to after.
+func DiffStates(before, after []byte) (*StateDiff, error) {
+	beforeInstances, beforeOutputs, err := diffDocument(before)
+	if err != nil {
+		return nil, err
+	}
+	afterInstances, afterOutputs, err := diffDocument(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &StateDiff{
+		Added:    []InstanceDiff{},
+		Removed:  []InstanceDiff{},
+		Modified: []InstanceDiff{},
+		Outputs:  []OutputDiff{},
+	}
+
+	for _, addr := range sortedKeys(beforeInstances, afterInstances) {
+		old, inOld := beforeInstances[addr]
+		cur, inNew := afterInstances[addr]
+		switch {
+		case !inOld:
+			diff.Added = append(diff.Added, instanceDiff(addr, cur))
+		case !inNew:
+			diff.Removed = append(diff.Removed, instanceDiff(addr, old))
+		default:
+			sensitive := append(append([][]interface{}{}, old.sensitive...), cur.sensitive...)
+			var changes []AttributeChange
+			diffValues(nil, old.attributes, cur.attributes, sensitive, &changes)
+			if len(changes) > 0 {
+				d := instanceDiff(addr, cur)
+				d.Changes = changes
+				diff.Modified = append(diff.Modified, d)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(beforeOutputs, afterOutputs) {
+		old, inOld := beforeOutputs[name]
+		cur, inNew := afterOutputs[name]
+
+		change := OutputDiff{Name: name, Sensitive: old.Sensitive || cur.Sensitive}
+		switch {
+		case !inOld:
+			change.Action = "added"
+			change.After = cur.Value
+		case !inNew:
+			change.Action = "removed"
+			change.Before = old.Value
+		case reflect.DeepEqual(old.Value, cur.Value) && reflect.DeepEqual(old.Type, cur.Type):
+			continue
+		default:
+			change.Action = "changed"
+			change.Before = old.Value
+			change.After = cur.Value
+		}
+		if change.Sensitive {
+			if inOld {
+				change.Before = SensitiveValue
+			}
+			if inNew {
+				change.After = SensitiveValue
+			}
+		}
+		diff.Outputs = append(diff.Outputs, change)
+	}
+
+	return diff, nil
+}
+
+// diffDocument indexes the managed resource instances of a state document by
+// address, along with its outputs.
+func diffDocument(body []byte) (map[string]diffInstance, map[string]StateOutput, error) {
+	var doc struct {
+		Outputs   map[string]StateOutput `json:"outputs"`
+		Resources []json.RawMessage      `json:"resources"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidState, err)
+	}
+
+	instances := make(map[string]diffInstance)
+	for _, raw := range doc.Resources {
+		var res stateResource
+		var sensitive struct {
+			Instances []struct {
+				SensitiveAttributes []json.RawMessage `json:"sensitive_attributes"`
+			} `json:"instances"`
+		}
+		if err := json.Unmarshal(raw, &res); err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrInvalidState, err)
+		}
+		json.Unmarshal(raw, &sensitive)
+
+		if res.Mode != "" && res.Mode != "managed" {
+			continue
+		}
+
+		addr := resourceAddress(res.Module, "managed", res.Type, res.Name)
+		for i, inst := range res.Instances {
+			var paths [][]interface{}
+			if i < len(sensitive.Instances) {
+				paths = sensitivePaths(sensitive.Instances[i].SensitiveAttributes)
+			}
+			instances[addr+indexKey(inst.IndexKey)] = diffInstance{
+				resource:   &res,
+				attributes: inst.Attributes,
+				sensitive:  paths,
+			}
+		}
+	}
+
+	if doc.Outputs == nil {
+		doc.Outputs = map[string]StateOutput{}
+	}
+	return instances, doc.Outputs, nil
+}
+
+func instanceDiff(addr string, inst diffInstance) InstanceDiff {
+	return InstanceDiff{
+		Address:  addr,
+		Type:     inst.resource.Type,
+		Provider: providerSource(inst.resource.Provider),
+	}
+}
+
+// diffValues walks two attribute values in parallel and records every
+// scalar that differs. A missing side of a map or list is treated as empty,
+// so added and removed nested values are reported per leaf too.
+func diffValues(path []interface{}, before, after interface{}, sensitive [][]interface{}, changes *[]AttributeChange) {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if (beforeIsMap || before == nil) && (afterIsMap || after == nil) && (beforeIsMap || afterIsMap) {
+		for _, key := range sortedKeys(beforeMap, afterMap) {
+			diffValues(appendStep(path, key), beforeMap[key], afterMap[key], sensitive, changes)
+		}
+		return
+	}
+
+	beforeList, beforeIsList := before.([]interface{})
+	afterList, afterIsList := after.([]interface{})
+	if (beforeIsList || before == nil) && (afterIsList || after == nil) && (beforeIsList || afterIsList) {
+		n := len(beforeList)
+		if len(afterList) > n {
+			n = len(afterList)
+		}
+		for i := 0; i < n; i++ {
+			var b, a interface{}
+			if i < len(beforeList) {
+				b = beforeList[i]
+			}
+			if i < len(afterList) {
+				a = afterList[i]
+			}
+			diffValues(appendStep(path, i), b, a, sensitive, changes)
+		}
+		return
+	}
+
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+
+	change := AttributeChange{Path: formatPath(path), Before: before, After: after}
+	if isSensitivePath(path, sensitive) {
+		change.Sensitive = true
+		if before != nil {
+			change.Before = SensitiveValue
+		}
+		if after != nil {
+			change.After = SensitiveValue
+		}
+	}
+	*changes = append(*changes, change)
+}
+
+// sensitivePaths decodes the sensitive_attributes of a state instance, a
+// list of paths made of get_attr and index steps.
+func sensitivePaths(raw []json.RawMessage) [][]interface{} {
+	var paths [][]interface{}
+	for _, r := range raw {
+		var steps []struct {
+			Type  string      `json:"type"`
+			Value interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(r, &steps); err != nil {
+			continue
+		}
+
+		path := make([]interface{}, 0, len(steps))
+		for _, step := range steps {
+			// Index steps hold a typed value: {"value": 0, "type": "number"}.
+			if typed, ok := step.Value.(map[string]interface{}); ok {
+				step.Value = typed["value"]
+			}
+			if n, ok := step.Value.(float64); ok {
+				path = append(path, int(n))
+			} else {
+				path = append(path, fmt.Sprint(step.Value))
+			}
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// isSensitivePath reports whether a value at path is, or contains, a
+// sensitive value.
+func isSensitivePath(path []interface{}, sensitive [][]interface{}) bool {
+	for _, s := range sensitive {
+		n := len(s)
+		if len(path) < n {
+			n = len(path)
+		}
+		if reflect.DeepEqual(path[:n], s[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendStep(path []interface{}, step interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(path)+1), path...), step)
+}
+
+func formatPath(path []interface{}) string {
+	var b strings.Builder
+	for _, step := range path {
+		switch s := step.(type) {
+		case int:
+			b.WriteString("[" + strconv.Itoa(s) + "]")
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(fmt.Sprint(s))
+		}
+	}
+	return b.String()
+}
+
+// indexKey renders the index of a resource instance, [0] or ["key"].
+func indexKey(key interface{}) string {
+	switch k := key.(type) {
+	case nil:
+		return ""
+	case float64:
+		return "[" + strconv.Itoa(int(k)) + "]"
+	default:
+		return "[" + strconv.Quote(fmt.Sprint(k)) + "]"
+	}
+}
+
+func sortedKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	keys := make([]string, 0, len(a)+len(b))
+	for _, m := range []map[string]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stateResource is a resource block of a terraform state document.
+type stateResource struct {
+	Module    string `json:"module"`
+	Mode      string `json:"mode"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider"`
+	Instances []struct {
+		IndexKey   interface{}            `json:"index_key"`
+		Attributes map[string]interface{} `json:"attributes"`
+	} `json:"instances"`
+}
+
+// parseResources reads the resource inventory of a state document. It also
+// returns the number of managed resource instances, which is what the state
+// version reports as its resource count.
+func parseResources(body []byte) ([]models.StateResource, int, error) {
+	var doc struct {
+		Resources []stateResource `json:"resources"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrInvalidState, err)
+	}
+
+	resources := make([]models.StateResource, 0, len(doc.Resources))
+	count := 0
+	for _, res := range doc.Resources {
+		ids := pq.StringArray{}
+		for _, inst := range res.Instances {
+			if id, ok := inst.Attributes["id"].(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+
+		mode := res.Mode
+		if mode == "" {
+			mode = "managed"
+		}
+		if mode == "managed" {
+			count += len(res.Instances)
+		}
+
+		resources = append(resources, models.StateResource{
+			Address:       resourceAddress(res.Module, mode, res.Type, res.Name),
+			Module:        res.Module,
+			Mode:          mode,
+			Type:          res.Type,
+			Name:          res.Name,
+			Provider:      providerSource(res.Provider),
+			InstanceCount: len(res.Instances),
+			InstanceIDs:   ids,
+		})
+	}
+
+	return resources, count, nil
+}
+
+// replaceResources makes resources the inventory of a workspace.
+func replaceResources(tx *gorm.DB, workspaceID, versionID string, resources []models.StateResource) error {
+	if err := tx.Where("workspace_id = ?", workspaceID).Delete(&models.StateResource{}).Error; err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+
+	for i := range resources {
+		resources[i].WorkspaceID = workspaceID
+		resources[i].StateVersionID = versionID
+	}
+	return tx.CreateInBatches(resources, 500).Error
+}
+
+// IndexCurrent rebuilds the resource inventory of every workspace from its
+// current state version, for state written before the inventory existed. It
+// returns how many workspaces it indexed.
+func (s *StateService) IndexCurrent() (int, error) {
+	var workspaces []models.Workspace
+	if err := s.db.Select("id, current_state_id").Where("current_state_id IS NOT NULL").Find(&workspaces).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, ws := range workspaces {
+		var version models.StateVersion
+		if err := s.db.First(&version, "id = ?", *ws.CurrentStateID).Error; err != nil {
+			return count, err
+		}
+
+		body, err := s.Data(&version)
+		if err != nil {
+			return count, err
+		}
+		resources, total, err := parseResources(body)
+		if err != nil {
+			return count, fmt.Errorf("state version %s: %w", version.ID, err)
+		}
+
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			// Skip workspaces whose state moved on since they were read; the
+			// write that moved it indexed the new state.
+			var current models.Workspace
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "current_state_id").First(&current, "id = ?", ws.ID).Error; err != nil {
+				return err
+			}
+			if current.CurrentStateID == nil || *current.CurrentStateID != version.ID {
+				return nil
+			}
+
+			if err := replaceResources(tx, ws.ID, version.ID, resources); err != nil {
+				return err
+			}
+			return tx.Model(&models.StateVersion{}).Where("id = ?", version.ID).Update("resource_count", total).Error
+		})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// resourceAddress builds the address terraform uses for a resource, e.g.
+// module.network.data.aws_vpc.main.
+func resourceAddress(module, mode, typ, name string) string {
+	addr := typ + "." + name
+	if mode == "data" {
+		addr = "data." + addr
+	}
+	if module != "" {
+		addr = module + "." + addr
+	}
+	return addr
+}
+
+// providerSource extracts the provider source address from a state provider
+// reference such as provider["registry.terraform.io/hashicorp/aws"].east.
+func providerSource(ref string) string {
+	if start := strings.Index(ref, `["`); start >= 0 {
+		if end := strings.Index(ref[start+2:], `"]`); end >= 0 {
+			return ref[start+2 : start+2+end]
+		}
+	}
+	// State written by terraform 0.12 uses provider.aws or provider.aws.alias.
+	name, _, _ := strings.Cut(strings.TrimPrefix(ref, "provider."), ".")
+	return name
+}
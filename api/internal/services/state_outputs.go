@@ -0,0 +1,41 @@
+package services
+
+import (
+	"encoding/json"
+)
+
+// StateOutput is a root module output of a state document. Type is the
+// output's type in terraform's JSON type notation, e.g. "string" or
+// ["map","string"], so clients can render values without guessing.
+type StateOutput struct {
+	Value     interface{} `json:"value"`
+	Type      interface{} `json:"type"`
+	Sensitive bool        `json:"sensitive"`
+}
+
+// ParseOutputs reads the outputs stored with a state version.
+func ParseOutputs(outputs string) (map[string]StateOutput, error) {
+	parsed := map[string]StateOutput{}
+	if outputs == "" {
+		return parsed, nil
+	}
+	if err := json.Unmarshal([]byte(outputs), &parsed); err != nil {
+		return nil, err
+	}
+	if parsed == nil {
+		parsed = map[string]StateOutput{}
+	}
+	return parsed, nil
+}
+
+// MaskOutputs replaces the values of sensitive outputs. Their type is kept.
+func MaskOutputs(outputs map[string]StateOutput) map[string]StateOutput {
+	masked := make(map[string]StateOutput, len(outputs))
+	for name, output := range outputs {
+		if output.Sensitive {
+			output.Value = SensitiveValue
+		}
+		masked[name] = output
+	}
+	return masked
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/terraconsole/api/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RetentionPolicy bounds how many state versions a workspace keeps. A
+// version is kept while it is one of the newest KeepVersions or younger than
+// KeepDays days; a zero limit does not keep anything by itself. The current
+// state and state that runs awaiting apply were planned against are always
+// kept.
+type RetentionPolicy struct {
+	KeepVersions int `json:"keep_versions"`
+	KeepDays     int `json:"keep_days"`
+}
+
+// Enabled reports whether the policy deletes anything at all.
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepVersions > 0 || p.KeepDays > 0
+}
+
+// EffectiveRetention combines a workspace's retention overrides with its
+// organization's policy.
+func EffectiveRetention(ws *models.Workspace, org *models.Organization) RetentionPolicy {
+	policy := RetentionPolicy{KeepVersions: org.StateRetentionVersions, KeepDays: org.StateRetentionDays}
+	if ws.StateRetentionVersions != nil {
+		policy.KeepVersions = *ws.StateRetentionVersions
+	}
+	if ws.StateRetentionDays != nil {
+		policy.KeepDays = *ws.StateRetentionDays
+	}
+	return policy
+}
+
+// RetentionReport sums up a retention pass: how many versions it deleted,
+// from how many workspaces, and the stored state size it freed.
+type RetentionReport struct {
+	Workspaces      int   `json:"workspaces"`
+	VersionsDeleted int   `json:"versions_deleted"`
+	BytesReclaimed  int64 `json:"bytes_reclaimed"`
+}
+
+// EnforceRetention deletes the state versions that fall outside the
+// retention policy of their workspace.
+func (s *StateService) EnforceRetention(now time.Time) (*RetentionReport, error) {
+	var workspaces []struct {
+		ID           string
+		KeepVersions int
+		KeepDays     int
+	}
+	err := s.db.Table("workspaces").
+		Select("workspaces.id, COALESCE(workspaces.state_retention_versions, organizations.state_retention_versions, 0) AS keep_versions, COALESCE(workspaces.state_retention_days, organizations.state_retention_days, 0) AS keep_days").
+		Joins("JOIN projects ON projects.id = workspaces.project_id").
+		Joins("JOIN organizations ON organizations.id = projects.organization_id").
+		Where("workspaces.deleted_at IS NULL").
+		Find(&workspaces).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RetentionReport{}
+	for _, ws := range workspaces {
+		policy := RetentionPolicy{KeepVersions: ws.KeepVersions, KeepDays: ws.KeepDays}
+		if !policy.Enabled() {
+			continue
+		}
+
+		deleted, bytes, err := s.pruneWorkspace(ws.ID, policy, now)
+		if err != nil {
+			return report, err
+		}
+		if deleted > 0 {
+			report.Workspaces++
+		}
+		report.VersionsDeleted += deleted
+		report.BytesReclaimed += bytes
+	}
+	return report, nil
+}
+
+// pruneWorkspace deletes the expired state versions of one workspace, and
+// their blobs, and returns how many it deleted and the size of their state.
+func (s *StateService) pruneWorkspace(workspaceID string, policy RetentionPolicy, now time.Time) (int, int64, error) {
+	var deleted []struct {
+		StateBlob string
+		Size      int64
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Holding the workspace row keeps the current state from moving
+		// while expired versions are picked.
+		var workspace models.Workspace
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "current_state_id").First(&workspace, "id = ?", workspaceID).Error; err != nil {
+			return err
+		}
+
+		expired := tx.Model(&models.StateVersion{}).Select("id").Where("workspace_id = ?", workspaceID)
+		if policy.KeepVersions > 0 {
+			expired = expired.Where("id NOT IN (?)", tx.Model(&models.StateVersion{}).
+				Select("id").
+				Where("workspace_id = ?", workspaceID).
+				Order("created_at DESC, serial DESC").
+				Limit(policy.KeepVersions))
+		}
+		if policy.KeepDays > 0 {
+			expired = expired.Where("created_at < ?", now.AddDate(0, 0, -policy.KeepDays))
+		}
+		if workspace.CurrentStateID != nil {
+			expired = expired.Where("id <> ?", *workspace.CurrentStateID)
+		}
+		// Only plans that can still be applied need the state they were
+		// made against.
+		expired = expired.Where("NOT EXISTS (?)", tx.Model(&models.Run{}).
+			Select("1").
+			Where("runs.workspace_id = state_versions.workspace_id AND runs.plan_state_lineage = state_versions.lineage AND runs.plan_state_serial = state_versions.serial").
+			Where("runs.status IN ?", []models.RunStatus{
+				models.RunStatusPlanned,
+				models.RunStatusNeedsConfirm,
+				models.RunStatusApplyQueued,
+				models.RunStatusApplying,
+			}))
+
+		return tx.Raw("DELETE FROM state_versions WHERE id IN (?) RETURNING COALESCE(state_blob, '') AS state_blob, COALESCE(octet_length(state), state_size, 0) AS size", expired).
+			Scan(&deleted).Error
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var bytes int64
+	for _, version := range deleted {
+		bytes += version.Size
+		if version.StateBlob == "" {
+			continue
+		}
+		// Blobs are content-addressed; keep one that another version uses.
+		var refs int64
+		if err := s.db.Model(&models.StateVersion{}).Where("state_blob = ?", version.StateBlob).Count(&refs).Error; err != nil {
+			return len(deleted), bytes, err
+		}
+		if refs == 0 {
+			if err := s.blobs.Delete(version.StateBlob); err != nil {
+				log.Printf("State retention: failed to delete blob %s: %v", version.StateBlob, err)
+			}
+		}
+	}
+	return len(deleted), bytes, nil
+}
+
+// StartRetentionJanitor enforces retention policies every interval until ctx
+// is cancelled. Replicas may all run it; each workspace is pruned under a
+// row lock, so concurrent passes only find less to delete.
+func (s *StateService) StartRetentionJanitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			report, err := s.EnforceRetention(time.Now())
+			if err != nil {
+				log.Printf("State retention: %v", err)
+			} else if report.VersionsDeleted > 0 {
+				log.Printf("State retention: deleted %d state versions in %d workspaces, reclaimed %d bytes", report.VersionsDeleted, report.Workspaces, report.BytesReclaimed)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
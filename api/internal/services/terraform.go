@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	version "github.com/hashicorp/go-version"
+)
+
+// InstalledTerraformVersions scans the versions directory laid out as
+// <dir>/<version>/terraform and returns a map of version to binary path.
+func InstalledTerraformVersions(dir string) map[string]string {
+	installed := make(map[string]string)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return installed
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			binaryPath := filepath.Join(dir, entry.Name(), "terraform")
+			if _, err := os.Stat(binaryPath); err == nil {
+				installed[entry.Name()] = binaryPath
+			}
+		}
+	}
+
+	return installed
+}
+
+// ResolveTerraformBinary returns the binary path for the requested version.
+// "latest" (or an empty version) resolves to the newest installed release and
+// falls back to a terraform binary on PATH when nothing is installed.
+func ResolveTerraformBinary(dir, requested string) (string, error) {
+	installed := InstalledTerraformVersions(dir)
+
+	if requested != "" && requested != "latest" {
+		if path, ok := installed[requested]; ok {
+			return path, nil
+		}
+		return "", fmt.Errorf("terraform version %s is not installed", requested)
+	}
+
+	var newest *version.Version
+	var newestPath string
+	for v, path := range installed {
+		parsed, err := version.NewVersion(v)
+		if err != nil || parsed.Prerelease() != "" {
+			continue
+		}
+		if newest == nil || parsed.GreaterThan(newest) {
+			newest = parsed
+			newestPath = path
+		}
+	}
+	if newestPath != "" {
+		return newestPath, nil
+	}
+
+	path, err := exec.LookPath("terraform")
+	if err != nil {
+		return "", fmt.Errorf("no terraform versions are installed")
+	}
+	return path, nil
+}
@@ -1,130 +1,223 @@
-const API_BASE = '/api';
-
-async function request<T>(url: string, options: RequestInit = {}): Promise<T> {
-    const token = localStorage.getItem('token');
-
-    const headers: Record<string, string> = {
-        'Content-Type': 'application/json',
-        ...((options.headers as Record<string, string>) || {}),
-    };
-
-    if (token) {
-        headers['Authorization'] = `Bearer ${token}`;
-    }
-
-    const response = await fetch(`${API_BASE}${url}`, {
-        ...options,
-        headers,
-    });
-
-    if (response.status === 401) {
-        localStorage.removeItem('token');
-        window.location.href = '/login';
-        throw new Error('Unauthorized');
-    }
-
-    const data = await response.json();
-
-    if (!response.ok) {
-        throw new Error(data.error || 'Request failed');
-    }
-
-    return data as T;
-}
-
-// Auth
-export const auth = {
-    signup: (data: { email: string; username: string; password: string; full_name: string }) =>
-        request('/auth/signup', { method: 'POST', body: JSON.stringify(data) }),
-    login: (data: { email: string; password: string; totp_code?: string }) =>
-        request('/auth/login', { method: 'POST', body: JSON.stringify(data) }),
-    me: () => request('/auth/me'),
-    refresh: () => request('/auth/refresh', { method: 'POST' }),
-    setupMFA: () => request('/auth/mfa/setup', { method: 'POST' }),
-    verifyMFA: (code: string) =>
-        request('/auth/mfa/verify', { method: 'POST', body: JSON.stringify({ code }) }),
-    disableMFA: () => request('/auth/mfa/disable', { method: 'POST' }),
-};
-
-// Organizations
-export const orgs = {
-    list: () => request('/organizations'),
-    create: (data: { name: string; display_name?: string; email?: string; description?: string }) =>
-        request('/organizations', { method: 'POST', body: JSON.stringify(data) }),
-    get: (id: string) => request(`/organizations/${id}`),
-    update: (id: string, data: any) =>
-        request(`/organizations/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
-    delete: (id: string) => request(`/organizations/${id}`, { method: 'DELETE' }),
-    listMembers: (id: string) => request(`/organizations/${id}/members`),
-    addMember: (id: string, data: { email: string; role: string }) =>
-        request(`/organizations/${id}/members`, { method: 'POST', body: JSON.stringify(data) }),
-    updateMember: (orgId: string, memberId: string, data: { role: string }) =>
-        request(`/organizations/${orgId}/members/${memberId}`, { method: 'PUT', body: JSON.stringify(data) }),
-    removeMember: (orgId: string, memberId: string) =>
-        request(`/organizations/${orgId}/members/${memberId}`, { method: 'DELETE' }),
-};
-
-// Projects
-export const projects = {
-    list: (orgId: string) => request(`/organizations/${orgId}/projects`),
-    create: (orgId: string, data: { name: string; description?: string }) =>
-        request(`/organizations/${orgId}/projects`, { method: 'POST', body: JSON.stringify(data) }),
-    get: (id: string) => request(`/projects/${id}`),
-    update: (id: string, data: any) =>
-        request(`/projects/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
-    delete: (id: string) => request(`/projects/${id}`, { method: 'DELETE' }),
-};
-
-// Workspaces
-export const workspaces = {
-    list: (projectId: string) => request(`/projects/${projectId}/workspaces`),
-    create: (projectId: string, data: any) =>
-        request(`/projects/${projectId}/workspaces`, { method: 'POST', body: JSON.stringify(data) }),
-    get: (id: string) => request(`/workspaces/${id}`),
-    update: (id: string, data: any) =>
-        request(`/workspaces/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
-    delete: (id: string) => request(`/workspaces/${id}`, { method: 'DELETE' }),
-    lock: (id: string) => request(`/workspaces/${id}/lock`, { method: 'POST' }),
-    unlock: (id: string) => request(`/workspaces/${id}/unlock`, { method: 'POST' }),
-};
-
-// Variables
-export const variables = {
-    list: (wsId: string) => request(`/workspaces/${wsId}/variables`),
-    create: (wsId: string, data: any) =>
-        request(`/workspaces/${wsId}/variables`, { method: 'POST', body: JSON.stringify(data) }),
-    update: (wsId: string, varId: string, data: any) =>
-        request(`/workspaces/${wsId}/variables/${varId}`, { method: 'PUT', body: JSON.stringify(data) }),
-    delete: (wsId: string, varId: string) =>
-        request(`/workspaces/${wsId}/variables/${varId}`, { method: 'DELETE' }),
-};
-
-// Runs
-export const runs = {
-    list: (wsId: string) => request(`/workspaces/${wsId}/runs`),
-    create: (wsId: string, data: { operation: string; message?: string; auto_apply?: boolean }) =>
-        request(`/workspaces/${wsId}/runs`, { method: 'POST', body: JSON.stringify(data) }),
-    get: (id: string) => request(`/runs/${id}`),
-    getPlanLog: (id: string) => request(`/runs/${id}/plan-log`),
-    getApplyLog: (id: string) => request(`/runs/${id}/apply-log`),
-    approve: (id: string) => request(`/runs/${id}/approve`, { method: 'POST' }),
-    discard: (id: string) => request(`/runs/${id}/discard`, { method: 'POST' }),
-    cancel: (id: string) => request(`/runs/${id}/cancel`, { method: 'POST' }),
-};
-
-// State
-export const state = {
-    getCurrent: (wsId: string) => request(`/workspaces/${wsId}/state`),
-    listVersions: (wsId: string) => request(`/workspaces/${wsId}/state-versions`),
-    getVersion: (wsId: string, versionId: string) =>
-        request(`/workspaces/${wsId}/state-versions/${versionId}`),
-    getOutputs: (wsId: string) => request(`/workspaces/${wsId}/outputs`),
-};
-
-// Terraform Versions
-export const terraform = {
-    listVersions: () => request('/terraform/versions'),
-    listInstalled: () => request('/terraform/versions/installed'),
-    install: (version: string) =>
-        request(`/terraform/versions/${version}/install`, { method: 'POST' }),
-};
+const API_BASE = '/api';
+
+// Access tokens are short-lived. A request answered with 401 refreshes the
+// session once and is retried; concurrent requests share one refresh.
+let refreshing: Promise<boolean> | null = null;
+
+async function refreshSession(): Promise<boolean> {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) return false;
+
+    const response = await fetch(`${API_BASE}/auth/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    if (!response.ok) {
+        // Another tab may have rotated the refresh token meanwhile.
+        return localStorage.getItem('refresh_token') !== refreshToken;
+    }
+
+    const data = await response.json();
+    localStorage.setItem('token', data.token);
+    localStorage.setItem('refresh_token', data.refresh_token);
+    return true;
+}
+
+export function clearSession() {
+    localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
+}
+
+async function request<T>(url: string, options: RequestInit = {}, retry = true): Promise<T> {
+    const token = localStorage.getItem('token');
+
+    const headers: Record<string, string> = {
+        'Content-Type': 'application/json',
+        ...((options.headers as Record<string, string>) || {}),
+    };
+
+    if (token) {
+        headers['Authorization'] = `Bearer ${token}`;
+    }
+
+    const response = await fetch(`${API_BASE}${url}`, {
+        ...options,
+        headers,
+    });
+
+    if (response.status === 401 && token) {
+        if (retry) {
+            refreshing = refreshing || refreshSession().finally(() => { refreshing = null; });
+            if (await refreshing) {
+                return request<T>(url, options, false);
+            }
+        }
+        clearSession();
+        window.location.href = '/login';
+        throw new Error('Unauthorized');
+    }
+
+    const data = await response.json();
+
+    if (!response.ok) {
+        throw new Error(data.error || 'Request failed');
+    }
+
+    return data as T;
+}
+
+// Auth
+export const auth = {
+    signup: (data: { email: string; username: string; password: string; full_name: string }) =>
+        request('/auth/signup', { method: 'POST', body: JSON.stringify(data) }),
+    login: (data: { email: string; password: string; totp_code?: string }) =>
+        request('/auth/login', { method: 'POST', body: JSON.stringify(data) }),
+    me: () => request('/auth/me'),
+    logout: () => request('/auth/logout', { method: 'POST' }),
+    listSessions: () => request('/auth/sessions'),
+    revokeSession: (sessionId: string) => request(`/auth/sessions/${sessionId}`, { method: 'DELETE' }),
+    revokeAllSessions: () => request('/auth/sessions', { method: 'DELETE' }),
+    setupMFA: (code?: string) =>
+        request('/auth/mfa/setup', { method: 'POST', body: JSON.stringify({ code }) }),
+    verifyMFA: (code: string) =>
+        request('/auth/mfa/verify', { method: 'POST', body: JSON.stringify({ code }) }),
+    disableMFA: (code: string) =>
+        request('/auth/mfa/disable', { method: 'POST', body: JSON.stringify({ code }) }),
+    getRecoveryCodes: () => request('/auth/mfa/recovery-codes'),
+    regenerateRecoveryCodes: (code: string) =>
+        request('/auth/mfa/recovery-codes', { method: 'POST', body: JSON.stringify({ code }) }),
+    listTokens: () => request('/auth/tokens'),
+    createToken: (data: { description: string; expires_at?: string }) =>
+        request('/auth/tokens', { method: 'POST', body: JSON.stringify(data) }),
+    deleteToken: (tokenId: string) => request(`/auth/tokens/${tokenId}`, { method: 'DELETE' }),
+};
+
+// terraform login
+export const oauth = {
+    authorize: (params: Record<string, string>) =>
+        request('/oauth/authorize', { method: 'POST', body: JSON.stringify(params) }),
+};
+
+// Organizations
+export const orgs = {
+    list: () => request('/organizations'),
+    create: (data: { name: string; display_name?: string; email?: string; description?: string }) =>
+        request('/organizations', { method: 'POST', body: JSON.stringify(data) }),
+    get: (id: string) => request(`/organizations/${id}`),
+    update: (id: string, data: any) =>
+        request(`/organizations/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
+    delete: (id: string) => request(`/organizations/${id}`, { method: 'DELETE' }),
+    listMembers: (id: string) => request(`/organizations/${id}/members`),
+    addMember: (id: string, data: { email: string; role: string }) =>
+        request(`/organizations/${id}/members`, { method: 'POST', body: JSON.stringify(data) }),
+    updateMember: (orgId: string, memberId: string, data: { role: string }) =>
+        request(`/organizations/${orgId}/members/${memberId}`, { method: 'PUT', body: JSON.stringify(data) }),
+    removeMember: (orgId: string, memberId: string) =>
+        request(`/organizations/${orgId}/members/${memberId}`, { method: 'DELETE' }),
+    listTeams: (orgId: string) => request(`/organizations/${orgId}/teams`),
+    createTeam: (orgId: string, data: { name: string; description?: string }) =>
+        request(`/organizations/${orgId}/teams`, { method: 'POST', body: JSON.stringify(data) }),
+    getTeam: (orgId: string, teamId: string) => request(`/organizations/${orgId}/teams/${teamId}`),
+    updateTeam: (orgId: string, teamId: string, data: { name?: string; description?: string }) =>
+        request(`/organizations/${orgId}/teams/${teamId}`, { method: 'PUT', body: JSON.stringify(data) }),
+    deleteTeam: (orgId: string, teamId: string) =>
+        request(`/organizations/${orgId}/teams/${teamId}`, { method: 'DELETE' }),
+    addTeamMember: (orgId: string, teamId: string, userId: string) =>
+        request(`/organizations/${orgId}/teams/${teamId}/members`, { method: 'POST', body: JSON.stringify({ user_id: userId }) }),
+    removeTeamMember: (orgId: string, teamId: string, userId: string) =>
+        request(`/organizations/${orgId}/teams/${teamId}/members/${userId}`, { method: 'DELETE' }),
+    grantTeamAccess: (orgId: string, teamId: string, data: { project_id?: string; workspace_id?: string; access: string; permissions?: string[] }) =>
+        request(`/organizations/${orgId}/teams/${teamId}/access`, { method: 'POST', body: JSON.stringify(data) }),
+    revokeTeamAccess: (orgId: string, teamId: string, accessId: string) =>
+        request(`/organizations/${orgId}/teams/${teamId}/access/${accessId}`, { method: 'DELETE' }),
+    listTokens: (orgId: string) => request(`/organizations/${orgId}/tokens`),
+    createToken: (orgId: string, data: { description: string; team_id?: string; expires_at?: string }) =>
+        request(`/organizations/${orgId}/tokens`, { method: 'POST', body: JSON.stringify(data) }),
+    deleteToken: (orgId: string, tokenId: string) =>
+        request(`/organizations/${orgId}/tokens/${tokenId}`, { method: 'DELETE' }),
+};
+
+// Projects
+export const projects = {
+    list: (orgId: string) => request(`/organizations/${orgId}/projects`),
+    create: (orgId: string, data: { name: string; description?: string }) =>
+        request(`/organizations/${orgId}/projects`, { method: 'POST', body: JSON.stringify(data) }),
+    get: (id: string) => request(`/projects/${id}`),
+    update: (id: string, data: any) =>
+        request(`/projects/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
+    delete: (id: string) => request(`/projects/${id}`, { method: 'DELETE' }),
+};
+
+// Workspaces
+export const workspaces = {
+    list: (projectId: string) => request(`/projects/${projectId}/workspaces`),
+    create: (projectId: string, data: any) =>
+        request(`/projects/${projectId}/workspaces`, { method: 'POST', body: JSON.stringify(data) }),
+    get: (id: string) => request(`/workspaces/${id}`),
+    update: (id: string, data: any) =>
+        request(`/workspaces/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
+    delete: (id: string) => request(`/workspaces/${id}`, { method: 'DELETE' }),
+    lock: (id: string) => request(`/workspaces/${id}/lock`, { method: 'POST' }),
+    unlock: (id: string) => request(`/workspaces/${id}/unlock`, { method: 'POST' }),
+    getStateRetention: (id: string) => request(`/workspaces/${id}/state-retention`),
+    updateStateRetention: (id: string, data: { state_retention_versions: number | null; state_retention_days: number | null }) =>
+        request(`/workspaces/${id}/state-retention`, { method: 'PUT', body: JSON.stringify(data) }),
+};
+
+// Variables
+export const variables = {
+    list: (wsId: string) => request(`/workspaces/${wsId}/variables`),
+    create: (wsId: string, data: any) =>
+        request(`/workspaces/${wsId}/variables`, { method: 'POST', body: JSON.stringify(data) }),
+    update: (wsId: string, varId: string, data: any) =>
+        request(`/workspaces/${wsId}/variables/${varId}`, { method: 'PUT', body: JSON.stringify(data) }),
+    delete: (wsId: string, varId: string) =>
+        request(`/workspaces/${wsId}/variables/${varId}`, { method: 'DELETE' }),
+};
+
+// Runs
+export const runs = {
+    list: (wsId: string) => request(`/workspaces/${wsId}/runs`),
+    create: (wsId: string, data: { operation: string; message?: string; auto_apply?: boolean }) =>
+        request(`/workspaces/${wsId}/runs`, { method: 'POST', body: JSON.stringify(data) }),
+    get: (id: string) => request(`/runs/${id}`),
+    getPlanLog: (id: string) => request(`/runs/${id}/plan-log`),
+    getApplyLog: (id: string) => request(`/runs/${id}/apply-log`),
+    approve: (id: string) => request(`/runs/${id}/approve`, { method: 'POST' }),
+    discard: (id: string) => request(`/runs/${id}/discard`, { method: 'POST' }),
+    cancel: (id: string) => request(`/runs/${id}/cancel`, { method: 'POST' }),
+    forceCancel: (id: string) => request(`/runs/${id}/force-cancel`, { method: 'POST' }),
+};
+
+// State
+export const state = {
+    getCurrent: (wsId: string) => request(`/workspaces/${wsId}/state`),
+    listVersions: (wsId: string) => request(`/workspaces/${wsId}/state-versions`),
+    getVersion: (wsId: string, versionId: string) =>
+        request(`/workspaces/${wsId}/state-versions/${versionId}`),
+    diffVersions: (wsId: string, from: string | number, to: string | number) =>
+        request(`/workspaces/${wsId}/state-versions/${from}/diff/${to}`),
+    uploadVersion: (wsId: string, data: { state: string; md5?: string; sha256?: string; force?: boolean }) =>
+        request(`/workspaces/${wsId}/state-versions`, { method: 'POST', body: JSON.stringify(data) }),
+    rollback: (wsId: string, versionId: string, reason: string) =>
+        request(`/workspaces/${wsId}/state-versions/${versionId}/rollback`, { method: 'POST', body: JSON.stringify({ reason }) }),
+    getOutputs: (wsId: string) => request(`/workspaces/${wsId}/outputs`),
+    revealOutput: (wsId: string, name: string) =>
+        request(`/workspaces/${wsId}/outputs/${encodeURIComponent(name)}/reveal`),
+    getSharing: (wsId: string) => request(`/workspaces/${wsId}/remote-state-sharing`),
+    updateSharing: (wsId: string, data: { sharing: string; workspace_ids?: string[] }) =>
+        request(`/workspaces/${wsId}/remote-state-sharing`, { method: 'PUT', body: JSON.stringify(data) }),
+    listResources: (wsId: string, filters: Record<string, string> = {}) =>
+        request(`/workspaces/${wsId}/resources?${new URLSearchParams(filters)}`),
+    searchResources: (orgId: string, filters: Record<string, string>) =>
+        request(`/organizations/${orgId}/resources?${new URLSearchParams(filters)}`),
+};
+
+// Terraform Versions
+export const terraform = {
+    listVersions: () => request('/terraform/versions'),
+    listInstalled: () => request('/terraform/versions/installed'),
+    install: (version: string) =>
+        request(`/terraform/versions/${version}/install`, { method: 'POST' }),
+};
@@ -1,80 +1,88 @@
-import React, { createContext, useContext, useState, useEffect, ReactNode } from 'react';
-import { User, AuthResponse } from '../types';
-import { auth } from '../api/client';
-
-interface AuthContextType {
-    user: User | null;
-    token: string | null;
-    loading: boolean;
-    login: (email: string, password: string, totpCode?: string) => Promise<AuthResponse>;
-    signup: (email: string, username: string, password: string, fullName: string) => Promise<void>;
-    logout: () => void;
-    refreshUser: () => Promise<void>;
-}
-
-const AuthContext = createContext<AuthContextType | undefined>(undefined);
-
-export function AuthProvider({ children }: { children: ReactNode }) {
-    const [user, setUser] = useState<User | null>(null);
-    const [token, setToken] = useState<string | null>(localStorage.getItem('token'));
-    const [loading, setLoading] = useState(true);
-
-    useEffect(() => {
-        if (token) {
-            loadUser();
-        } else {
-            setLoading(false);
-        }
-    }, []);
-
-    const loadUser = async () => {
-        try {
-            const userData = await auth.me() as User;
-            setUser(userData);
-        } catch {
-            localStorage.removeItem('token');
-            setToken(null);
-        } finally {
-            setLoading(false);
-        }
-    };
-
-    const login = async (email: string, password: string, totpCode?: string): Promise<AuthResponse> => {
-        const data = await auth.login({ email, password, totp_code: totpCode }) as AuthResponse;
-        localStorage.setItem('token', data.token);
-        setToken(data.token);
-        setUser(data.user);
-        return data;
-    };
-
-    const signup = async (email: string, username: string, password: string, fullName: string) => {
-        const data = await auth.signup({ email, username, password, full_name: fullName }) as AuthResponse;
-        localStorage.setItem('token', data.token);
-        setToken(data.token);
-        setUser(data.user);
-    };
-
-    const logout = () => {
-        localStorage.removeItem('token');
-        setToken(null);
-        setUser(null);
-    };
-
-    const refreshUser = async () => {
-        await loadUser();
-    };
-
-    return (
-        <AuthContext.Provider value={{ user, token, loading, login, signup, logout, refreshUser }}>
-            {children}
-        </AuthContext.Provider>
-    );
-}
-
-export function useAuth() {
-    const context = useContext(AuthContext);
-    if (!context) {
-        throw new Error('useAuth must be used within an AuthProvider');
-    }
-    return context;
-}
+import React, { createContext, useContext, useState, useEffect, ReactNode } from 'react';
+import { User, AuthResponse } from '../types';
+import { auth, clearSession } from '../api/client';
+
+interface AuthContextType {
+    user: User | null;
+    token: string | null;
+    loading: boolean;
+    login: (email: string, password: string, totpCode?: string) => Promise<AuthResponse>;
+    signup: (email: string, username: string, password: string, fullName: string) => Promise<void>;
+    logout: () => void;
+    refreshUser: () => Promise<void>;
+}
+
+const AuthContext = createContext<AuthContextType | undefined>(undefined);
+
+export function AuthProvider({ children }: { children: ReactNode }) {
+    const [user, setUser] = useState<User | null>(null);
+    const [token, setToken] = useState<string | null>(localStorage.getItem('token'));
+    const [loading, setLoading] = useState(true);
+
+    useEffect(() => {
+        if (token) {
+            loadUser();
+        } else {
+            setLoading(false);
+        }
+    }, []);
+
+    const loadUser = async () => {
+        try {
+            const userData = await auth.me() as User;
+            setUser(userData);
+        } catch {
+            clearSession();
+            setToken(null);
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    const login = async (email: string, password: string, totpCode?: string): Promise<AuthResponse> => {
+        const data = await auth.login({ email, password, totp_code: totpCode }) as AuthResponse;
+        localStorage.setItem('token', data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
+        setToken(data.token);
+        setUser(data.user);
+        return data;
+    };
+
+    const signup = async (email: string, username: string, password: string, fullName: string) => {
+        const data = await auth.signup({ email, username, password, full_name: fullName }) as AuthResponse;
+        localStorage.setItem('token', data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
+        setToken(data.token);
+        setUser(data.user);
+    };
+
+    const logout = async () => {
+        // Revoke the session server-side; sign out locally either way.
+        try {
+            await auth.logout();
+        } catch {
+            // Already signed out
+        }
+        clearSession();
+        setToken(null);
+        setUser(null);
+    };
+
+    const refreshUser = async () => {
+        await loadUser();
+    };
+
+    return (
+        <AuthContext.Provider value={{ user, token, loading, login, signup, logout, refreshUser }}>
+            {children}
+        </AuthContext.Provider>
+    );
+}
+
+export function useAuth() {
+    const context = useContext(AuthContext);
+    if (!context) {
+        throw new Error('useAuth must be used within an AuthProvider');
+    }
+    return context;
+}
@@ -1,28 +1,28 @@
-import React from 'react';
-import ReactDOM from 'react-dom/client';
-import { BrowserRouter } from 'react-router-dom';
-import { Toaster } from 'react-hot-toast';
-import { AuthProvider } from './context/AuthContext';
-import App from './App';
-import './index.css';
-
-ReactDOM.createRoot(document.getElementById('root')!).render(
-    <React.StrictMode>
-        <BrowserRouter>
-            <AuthProvider>
-                <App />
-                <Toaster
-                    position="top-right"
-                    toastOptions={{
-                        style: {
-                            background: '#1a2035',
-                            color: '#f1f5f9',
-                            border: '1px solid rgba(148, 163, 184, 0.1)',
-                            borderRadius: '10px',
-                        },
-                    }}
-                />
-            </AuthProvider>
-        </BrowserRouter>
-    </React.StrictMode>
-);
+import React from 'react';
+import ReactDOM from 'react-dom/client';
+import { BrowserRouter } from 'react-router-dom';
+import { Toaster } from 'react-hot-toast';
+import { AuthProvider } from './context/AuthContext';
+import App from './App';
+import './index.css';
+
+ReactDOM.createRoot(document.getElementById('root')!).render(
+    <React.StrictMode>
+        <BrowserRouter>
+            <AuthProvider>
+                <App />
+                <Toaster
+                    position="top-right"
+                    toastOptions={{
+                        style: {
+                            background: '#1a2035',
+                            color: '#f1f5f9',
+                            border: '1px solid rgba(148, 163, 184, 0.1)',
+                            borderRadius: '10px',
+                        },
+                    }}
+                />
+            </AuthProvider>
+        </BrowserRouter>
+    </React.StrictMode>
+);
@@ -0,0 +1,60 @@
+import React, { useState } from 'react';
+import { useSearchParams, useNavigate } from 'react-router-dom';
+import { useAuth } from '../context/AuthContext';
+import { oauth } from '../api/client';
+import toast from 'react-hot-toast';
+
+const OAUTH_PARAMS = ['client_id', 'redirect_uri', 'response_type', 'state', 'code_challenge', 'code_challenge_method'];
+
+export default function OAuthAuthorizePage() {
+    const [searchParams] = useSearchParams();
+    const { user } = useAuth();
+    const navigate = useNavigate();
+    const [loading, setLoading] = useState(false);
+
+    const handleAuthorize = async () => {
+        setLoading(true);
+        try {
+            const params: Record<string, string> = {};
+            OAUTH_PARAMS.forEach(name => { params[name] = searchParams.get(name) || ''; });
+            const data = await oauth.authorize(params) as { redirect_url: string };
+            window.location.href = data.redirect_url;
+        } catch (err: any) {
+            toast.error(err.message);
+            setLoading(false);
+        }
+    };
+
+    return (
+        <div className="auth-page">
+            <div className="auth-card">
+                <div className="auth-logo">
+                    <div style={{ fontSize: '2.5rem', marginBottom: '8px' }}>🔑</div>
+                    <h1>Authorize Terraform</h1>
+                    <p>Terraform CLI on this computer is asking for an API token</p>
+                </div>
+
+                <p className="text-muted" style={{ marginBottom: '16px', fontSize: '0.875rem' }}>
+                    Signed in as <strong>{user?.username}</strong>. Terraform will act as you and
+                    store the token in its credentials file. You can revoke it under Settings.
+                </p>
+
+                <button
+                    className="btn btn-primary btn-lg w-full"
+                    onClick={handleAuthorize}
+                    disabled={loading}
+                >
+                    {loading ? (
+                        <><span className="loading-spinner"></span> Authorizing...</>
+                    ) : (
+                        'Authorize'
+                    )}
+                </button>
+
+                <div className="auth-footer">
+                    <a href="#" onClick={e => { e.preventDefault(); navigate('/'); }}>Cancel</a>
+                </div>
+            </div>
+        </div>
+    );
+}
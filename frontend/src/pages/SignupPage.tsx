@@ -1,117 +1,117 @@
-import React, { useState } from 'react';
-import { useNavigate, Link } from 'react-router-dom';
-import { useAuth } from '../context/AuthContext';
-import toast from 'react-hot-toast';
-
-export default function SignupPage() {
-    const [email, setEmail] = useState('');
-    const [username, setUsername] = useState('');
-    const [password, setPassword] = useState('');
-    const [fullName, setFullName] = useState('');
-    const [loading, setLoading] = useState(false);
-    const { signup } = useAuth();
-    const navigate = useNavigate();
-
-    const handleSubmit = async (e: React.FormEvent) => {
-        e.preventDefault();
-        if (password.length < 8) {
-            toast.error('Password must be at least 8 characters');
-            return;
-        }
-        setLoading(true);
-        try {
-            await signup(email, username, password, fullName);
-            toast.success('Account created successfully!');
-            navigate('/');
-        } catch (err: any) {
-            toast.error(err.message || 'Signup failed');
-        } finally {
-            setLoading(false);
-        }
-    };
-
-    return (
-        <div className="auth-page">
-            <div className="auth-card">
-                <div className="auth-logo">
-                    <div style={{ fontSize: '2.5rem', marginBottom: '8px' }}>🏗️</div>
-                    <h1>TerraConsole</h1>
-                    <p>Create your account</p>
-                </div>
-
-                <form onSubmit={handleSubmit}>
-                    <div className="form-group">
-                        <label className="form-label" htmlFor="fullName">Full Name</label>
-                        <input
-                            id="fullName"
-                            className="form-input"
-                            type="text"
-                            placeholder="John Doe"
-                            value={fullName}
-                            onChange={e => setFullName(e.target.value)}
-                            required
-                            autoFocus
-                        />
-                    </div>
-
-                    <div className="form-group">
-                        <label className="form-label" htmlFor="username">Username</label>
-                        <input
-                            id="username"
-                            className="form-input"
-                            type="text"
-                            placeholder="johndoe"
-                            value={username}
-                            onChange={e => setUsername(e.target.value)}
-                            required
-                        />
-                    </div>
-
-                    <div className="form-group">
-                        <label className="form-label" htmlFor="email">Email</label>
-                        <input
-                            id="email"
-                            className="form-input"
-                            type="email"
-                            placeholder="john@company.com"
-                            value={email}
-                            onChange={e => setEmail(e.target.value)}
-                            required
-                        />
-                    </div>
-
-                    <div className="form-group">
-                        <label className="form-label" htmlFor="password">Password</label>
-                        <input
-                            id="password"
-                            className="form-input"
-                            type="password"
-                            placeholder="Min 8 characters"
-                            value={password}
-                            onChange={e => setPassword(e.target.value)}
-                            required
-                            minLength={8}
-                        />
-                    </div>
-
-                    <button
-                        type="submit"
-                        className="btn btn-primary btn-lg w-full"
-                        disabled={loading}
-                    >
-                        {loading ? (
-                            <><span className="loading-spinner"></span> Creating account...</>
-                        ) : (
-                            'Create Account'
-                        )}
-                    </button>
-                </form>
-
-                <div className="auth-footer">
-                    Already have an account?{' '}
-                    <Link to="/login">Sign in</Link>
-                </div>
-            </div>
-        </div>
-    );
-}
+import React, { useState } from 'react';
+import { useNavigate, Link } from 'react-router-dom';
+import { useAuth } from '../context/AuthContext';
+import toast from 'react-hot-toast';
+
+export default function SignupPage() {
+    const [email, setEmail] = useState('');
+    const [username, setUsername] = useState('');
+    const [password, setPassword] = useState('');
+    const [fullName, setFullName] = useState('');
+    const [loading, setLoading] = useState(false);
+    const { signup } = useAuth();
+    const navigate = useNavigate();
+
+    const handleSubmit = async (e: React.FormEvent) => {
+        e.preventDefault();
+        if (password.length < 8) {
+            toast.error('Password must be at least 8 characters');
+            return;
+        }
+        setLoading(true);
+        try {
+            await signup(email, username, password, fullName);
+            toast.success('Account created successfully!');
+            navigate('/');
+        } catch (err: any) {
+            toast.error(err.message || 'Signup failed');
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    return (
+        <div className="auth-page">
+            <div className="auth-card">
+                <div className="auth-logo">
+                    <div style={{ fontSize: '2.5rem', marginBottom: '8px' }}>🏗️</div>
+                    <h1>TerraConsole</h1>
+                    <p>Create your account</p>
+                </div>
+
+                <form onSubmit={handleSubmit}>
+                    <div className="form-group">
+                        <label className="form-label" htmlFor="fullName">Full Name</label>
+                        <input
+                            id="fullName"
+                            className="form-input"
+                            type="text"
+                            placeholder="John Doe"
+                            value={fullName}
+                            onChange={e => setFullName(e.target.value)}
+                            required
+                            autoFocus
+                        />
+                    </div>
+
+                    <div className="form-group">
+                        <label className="form-label" htmlFor="username">Username</label>
+                        <input
+                            id="username"
+                            className="form-input"
+                            type="text"
+                            placeholder="johndoe"
+                            value={username}
+                            onChange={e => setUsername(e.target.value)}
+                            required
+                        />
+                    </div>
+
+                    <div className="form-group">
+                        <label className="form-label" htmlFor="email">Email</label>
+                        <input
+                            id="email"
+                            className="form-input"
+                            type="email"
+                            placeholder="john@company.com"
+                            value={email}
+                            onChange={e => setEmail(e.target.value)}
+                            required
+                        />
+                    </div>
+
+                    <div className="form-group">
+                        <label className="form-label" htmlFor="password">Password</label>
+                        <input
+                            id="password"
+                            className="form-input"
+                            type="password"
+                            placeholder="Min 8 characters"
+                            value={password}
+                            onChange={e => setPassword(e.target.value)}
+                            required
+                            minLength={8}
+                        />
+                    </div>
+
+                    <button
+                        type="submit"
+                        className="btn btn-primary btn-lg w-full"
+                        disabled={loading}
+                    >
+                        {loading ? (
+                            <><span className="loading-spinner"></span> Creating account...</>
+                        ) : (
+                            'Create Account'
+                        )}
+                    </button>
+                </form>
+
+                <div className="auth-footer">
+                    Already have an account?{' '}
+                    <Link to="/login">Sign in</Link>
+                </div>
+            </div>
+        </div>
+    );
+}
@@ -1,134 +1,273 @@
-export interface User {
-    id: string;
-    email: string;
-    username: string;
-    full_name: string;
-    avatar_url: string;
-    mfa_enabled: boolean;
-    is_active: boolean;
-    last_login_at: string | null;
-    created_at: string;
-    updated_at: string;
-}
-
-export interface AuthResponse {
-    token: string;
-    expires_at: number;
-    user: User;
-}
-
-export interface Organization {
-    id: string;
-    name: string;
-    display_name: string;
-    email: string;
-    description: string;
-    owner_id: string;
-    created_at: string;
-    updated_at: string;
-}
-
-export interface OrgMember {
-    id: string;
-    organization_id: string;
-    user_id: string;
-    user?: User;
-    role: 'owner' | 'admin' | 'member' | 'viewer';
-    created_at: string;
-}
-
-export interface Project {
-    id: string;
-    name: string;
-    description: string;
-    organization_id: string;
-    created_at: string;
-    updated_at: string;
-}
-
-export interface Workspace {
-    id: string;
-    name: string;
-    description: string;
-    project_id: string;
-    terraform_version: string;
-    working_directory: string;
-    auto_apply: boolean;
-    execution_mode: string;
-    locked: boolean;
-    locked_by: string | null;
-    locked_at: string | null;
-    vcs_repo_url: string;
-    vcs_branch: string;
-    current_state_id: string | null;
-    created_at: string;
-    updated_at: string;
-}
-
-export interface Variable {
-    id: string;
-    workspace_id: string;
-    key: string;
-    value: string;
-    description: string;
-    category: 'terraform' | 'env';
-    hcl: boolean;
-    sensitive: boolean;
-    created_at: string;
-    updated_at: string;
-}
-
-export type RunStatus =
-    | 'pending'
-    | 'planning'
-    | 'planned'
-    | 'needs_confirmation'
-    | 'applying'
-    | 'applied'
-    | 'errored'
-    | 'cancelled'
-    | 'discarded'
-    | 'planned_and_finished';
-
-export type RunOperation = 'plan' | 'plan_and_apply' | 'destroy' | 'refresh';
-
-export interface Run {
-    id: string;
-    workspace_id: string;
-    status: RunStatus;
-    operation: RunOperation;
-    message: string;
-    is_destroy: boolean;
-    auto_apply: boolean;
-    terraform_version: string;
-    created_by: string;
-    creator?: User;
-    resources_added: number;
-    resources_changed: number;
-    resources_deleted: number;
-    started_at: string | null;
-    plan_completed_at: string | null;
-    applied_at: string | null;
-    completed_at: string | null;
-    created_at: string;
-    updated_at: string;
-}
-
-export interface StateVersion {
-    id: string;
-    workspace_id: string;
-    run_id: string | null;
-    serial: number;
-    lineage: string;
-    state_hash: string;
-    outputs: string;
-    resource_count: number;
-    created_at: string;
-    created_by: string;
-}
-
-export interface TFVersion {
-    version: string;
-    installed: boolean;
-    path?: string;
-}
+export interface User {
+    id: string;
+    email: string;
+    username: string;
+    full_name: string;
+    avatar_url: string;
+    mfa_enabled: boolean;
+    is_active: boolean;
+    last_login_at: string | null;
+    created_at: string;
+    updated_at: string;
+}
+
+export interface AuthResponse {
+    token: string;
+    expires_at: number;
+    refresh_token: string;
+    user: User;
+}
+
+export interface Session {
+    id: string;
+    user_id: string;
+    user_agent: string;
+    ip_address: string;
+    created_at: string;
+    last_used_at: string;
+    expires_at: string;
+    revoked_at: string | null;
+    current: boolean;
+}
+
+export interface Organization {
+    id: string;
+    name: string;
+    display_name: string;
+    email: string;
+    description: string;
+    max_concurrent_runs: number;
+    state_retention_versions: number;
+    state_retention_days: number;
+    require_mfa: boolean;
+    owner_id: string;
+    created_at: string;
+    updated_at: string;
+}
+
+export interface OrgMember {
+    id: string;
+    organization_id: string;
+    user_id: string;
+    user?: User;
+    role: 'owner' | 'admin' | 'member' | 'viewer';
+    created_at: string;
+}
+
+export interface Team {
+    id: string;
+    organization_id: string;
+    name: string;
+    description: string;
+    created_at: string;
+    updated_at: string;
+    members?: TeamMember[];
+    access?: TeamAccess[];
+}
+
+export interface TeamMember {
+    id: string;
+    team_id: string;
+    user_id: string;
+    user?: User;
+    created_at: string;
+}
+
+export type Permission =
+    | 'read'
+    | 'read-state'
+    | 'reveal-outputs'
+    | 'queue-run'
+    | 'lock'
+    | 'apply'
+    | 'write-variables'
+    | 'manage-workspaces';
+
+// A team's grant on a project or a single workspace.
+export interface TeamAccess {
+    id: string;
+    team_id: string;
+    project_id: string | null;
+    workspace_id: string | null;
+    access: 'read' | 'plan' | 'write' | 'admin' | 'custom';
+    permissions: Permission[] | null;
+    created_at: string;
+    updated_at: string;
+}
+
+export interface APIToken {
+    id: string;
+    user_id: string;
+    kind: 'user' | 'team' | 'organization';
+    organization_id: string | null;
+    team_id: string | null;
+    description: string;
+    last_used_at: string | null;
+    expires_at: string | null;
+    created_at: string;
+}
+
+// Returned once, when a token is created.
+export interface CreatedAPIToken extends APIToken {
+    token: string;
+}
+
+export interface Project {
+    id: string;
+    name: string;
+    description: string;
+    organization_id: string;
+    max_concurrent_runs: number;
+    created_at: string;
+    updated_at: string;
+}
+
+export interface Workspace {
+    id: string;
+    name: string;
+    description: string;
+    project_id: string;
+    terraform_version: string;
+    working_directory: string;
+    auto_apply: boolean;
+    execution_mode: string;
+    locked: boolean;
+    locked_by: string | null;
+    locked_at: string | null;
+    lock_info: StateLock | null;
+    vcs_repo_url: string;
+    vcs_branch: string;
+    current_state_id: string | null;
+    remote_state_sharing: RemoteStateSharing;
+    state_retention_versions: number | null;
+    state_retention_days: number | null;
+    created_at: string;
+    updated_at: string;
+}
+
+export type RemoteStateSharing = 'none' | 'organization' | 'workspaces';
+
+export interface StateLock {
+    id: string;
+    operation: string;
+    info: string;
+    who: string;
+    version: string;
+    created: string;
+    path: string;
+}
+
+export interface Variable {
+    id: string;
+    workspace_id: string;
+    key: string;
+    value: string;
+    description: string;
+    category: 'terraform' | 'env';
+    hcl: boolean;
+    sensitive: boolean;
+    created_at: string;
+    updated_at: string;
+}
+
+export type RunStatus =
+    | 'pending'
+    | 'planning'
+    | 'planned'
+    | 'needs_confirmation'
+    | 'apply_queued'
+    | 'applying'
+    | 'applied'
+    | 'errored'
+    | 'cancelled'
+    | 'discarded'
+    | 'planned_and_finished';
+
+export type RunOperation = 'plan' | 'plan_and_apply' | 'destroy' | 'refresh';
+
+export interface Run {
+    id: string;
+    workspace_id: string;
+    status: RunStatus;
+    operation: RunOperation;
+    message: string;
+    is_destroy: boolean;
+    auto_apply: boolean;
+    terraform_version: string;
+    created_by: string;
+    creator?: User;
+    resources_added: number;
+    resources_changed: number;
+    resources_deleted: number;
+    resources_replaced: number;
+    resources_imported: number;
+    plan_state_serial: number | null;
+    plan_state_lineage: string;
+    started_at: string | null;
+    plan_completed_at: string | null;
+    applied_at: string | null;
+    completed_at: string | null;
+    queue_position: number | null;
+    cancel_requested_at: string | null;
+    force_cancel_available_at: string | null;
+    created_at: string;
+    updated_at: string;
+}
+
+export interface StateVersion {
+    id: string;
+    workspace_id: string;
+    run_id: string | null;
+    serial: number;
+    lineage: string;
+    state_hash: string;
+    state_md5: string;
+    outputs: string;
+    resource_count: number;
+    created_at: string;
+    created_by: string;
+}
+
+// type is terraform's JSON type notation, e.g. "string" or ["map", "string"].
+// The value of a sensitive output is "***SENSITIVE***" until revealed.
+export interface StateOutput {
+    value: unknown;
+    type: unknown;
+    sensitive: boolean;
+}
+
+export interface StateResource {
+    id: string;
+    workspace_id: string;
+    state_version_id: string;
+    address: string;
+    module: string;
+    mode: 'managed' | 'data';
+    type: string;
+    name: string;
+    provider: string;
+    instance_count: number;
+    instance_ids: string[];
+}
+
+export interface StateInstanceDiff {
+    address: string;
+    type: string;
+    provider: string;
+    changes?: { path: string; before: unknown; after: unknown; sensitive?: boolean }[];
+}
+
+export interface StateDiff {
+    from: { id: string; serial: number; lineage: string; run_id: string | null; created_at: string };
+    to: { id: string; serial: number; lineage: string; run_id: string | null; created_at: string };
+    added: StateInstanceDiff[];
+    removed: StateInstanceDiff[];
+    modified: StateInstanceDiff[];
+    outputs: { name: string; action: 'added' | 'removed' | 'changed'; before: unknown; after: unknown; sensitive?: boolean }[];
+}
+
+export interface TFVersion {
+    version: string;
+    installed: boolean;
+    path?: string;
+}